@@ -3,36 +3,133 @@ package routes
 import (
 	fooditemservice "diet-app-backend/api/services/food_item_service"
 	foodservice "diet-app-backend/api/services/food_service"
+	goalservice "diet-app-backend/api/services/goal_service"
+	mealservice "diet-app-backend/api/services/meal_service"
+	measurementservice "diet-app-backend/api/services/measurement_service"
+	passwordservice "diet-app-backend/api/services/password_service"
+	recipeservice "diet-app-backend/api/services/recipe_service"
+	tokenservice "diet-app-backend/api/services/token_service"
+	twofactorservice "diet-app-backend/api/services/two_factor_service"
 	userservice "diet-app-backend/api/services/user_service"
+	"diet-app-backend/database/models"
 	"diet-app-backend/util/authentication"
 	"diet-app-backend/util/config"
+	"diet-app-backend/util/ratelimit"
+	"fmt"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+var admins = authentication.RequireRole(models.RoleAdmin)
+var catalogEditors = authentication.RequireRole(models.RoleDietitian, models.RoleAdmin)
+
 func SetupRouter() *gin.Engine {
 	router := gin.Default()
 
+	// Client addresses are read from X-Forwarded-For only when it is set by
+	// one of the proxies in front of the API, or anyone could change theirs
+	// to get past the rate limits
+	if err := router.SetTrustedProxies(config.AppConfig.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("the trusted proxies are invalid: %v", err))
+	}
+
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{config.AppConfig.FrontEndUrl}
 	corsConfig.AddAllowHeaders("Authorization")
 
 	router.Use(cors.New(corsConfig))
 
-	router.POST("login", userservice.Login)
+	// Every password check costs a bcrypt comparison, so logins are limited
+	// per address on top of locking accounts out after failed attempts
+	loginStore := ratelimit.NewStore(config.AppConfig.RateLimitStore)
+	loginRate := ratelimit.PerIP(loginStore, "login", config.AppConfig.LoginRateLimit, config.AppConfig.LoginRateWindow)
+	loginLockout := ratelimit.Lockout{
+		Store:       loginStore,
+		Name:        "login",
+		Key:         userservice.LoginEmail,
+		MaxFailures: config.AppConfig.LoginMaxFailures,
+		Window:      config.AppConfig.LoginFailureWindow,
+		Duration:    config.AppConfig.LoginLockout,
+		MaxDuration: config.AppConfig.LoginMaxLockout,
+	}
+
+	// Wrong codes count towards the lockout of the account like wrong passwords
+	twoFactorLockout := loginLockout
+	twoFactorLockout.Key = twofactorservice.ChallengeEmail
+
+	// The codes asked for by signed in users can't be guessed any faster
+	codeLockout := loginLockout
+	codeLockout.Name = "2fa"
+	codeLockout.Key = authentication.UserID
+
+	router.POST("login", loginRate(loginLockout.Wrap(userservice.Login)))
+	router.POST("login/2fa", loginRate(twoFactorLockout.Wrap(twofactorservice.PostTwoFactorLogin)))
 	router.POST("signup", userservice.Signup)
+	router.GET("verify", userservice.VerifyEmail)
+	router.POST("verify/resend", userservice.ResendVerification)
 	router.GET("user", authentication.Authenticate(userservice.GetUser))
+	router.PUT("user", authentication.Authenticate(userservice.PutUser))
+	router.DELETE("user", authentication.Authenticate(userservice.DeleteUser))
+	router.PUT("user/password", authentication.Authenticate(userservice.PutUserPassword))
+	router.PUT("user/timezone", authentication.Authenticate(userservice.PutUserTimezone))
+	router.PUT("user/profile", authentication.Authenticate(userservice.PutUserProfile))
+	router.PUT("user/:id/role", authentication.Authenticate(admins(userservice.PutUserRole)))
+
+	router.POST("user/2fa/enroll", authentication.Authenticate(twofactorservice.PostTwoFactorEnrollment))
+	router.POST("user/2fa/verify", authentication.Authenticate(codeLockout.Wrap(twofactorservice.PostTwoFactorVerification)))
+	router.POST("user/2fa/recovery-codes", authentication.Authenticate(codeLockout.Wrap(twofactorservice.PostRecoveryCodes)))
+	router.DELETE("user/2fa", authentication.Authenticate(twofactorservice.DeleteTwoFactor))
+
+	router.POST("password/forgot", passwordservice.PostForgotPassword)
+	router.POST("password/reset", passwordservice.PostResetPassword)
 
-	router.GET("food", foodservice.GetFoods)
-	router.GET("food/:id", foodservice.GetFood)
+	router.POST("token/refresh", tokenservice.Refresh)
+	router.POST("logout", authentication.Authenticate(tokenservice.Logout))
+	router.POST("logout/all", authentication.Authenticate(tokenservice.LogoutAll))
+
+	router.GET("food", authentication.OptionalAuthenticate(foodservice.GetFoods))
+	router.GET("food/:id", authentication.OptionalAuthenticate(foodservice.GetFood))
+	router.GET("food/barcode/:code", authentication.OptionalAuthenticate(foodservice.GetFoodByBarcode))
+	router.POST("food", authentication.Authenticate(catalogEditors(foodservice.PostFood)))
+	router.POST("food/import", authentication.Authenticate(admins(foodservice.PostFoodImport)))
+	router.PUT("food/:id", authentication.Authenticate(catalogEditors(foodservice.PutFood)))
+	router.DELETE("food/:id", authentication.Authenticate(admins(foodservice.DeleteFood)))
+
+	router.POST("user/custom-food", authentication.Authenticate(foodservice.PostCustomFood))
+	router.PUT("user/custom-food/:id", authentication.Authenticate(foodservice.PutCustomFood))
+	router.DELETE("user/custom-food/:id", authentication.Authenticate(foodservice.DeleteCustomFood))
+
+	router.GET("user/recipe/:id", authentication.Authenticate(recipeservice.GetRecipe))
+	router.POST("user/recipe", authentication.Authenticate(recipeservice.PostRecipe))
+	router.PUT("user/recipe/:id", authentication.Authenticate(recipeservice.PutRecipe))
+	router.DELETE("user/recipe/:id", authentication.Authenticate(recipeservice.DeleteRecipe))
+
+	router.GET("user/goal", authentication.Authenticate(goalservice.GetCalorieGoal))
+	router.GET("user/goal/history", authentication.Authenticate(goalservice.GetCalorieGoalHistory))
+	router.GET("user/goal/suggestion", authentication.Authenticate(goalservice.GetCalorieGoalSuggestion))
+	router.PUT("user/goal", authentication.Authenticate(goalservice.PutCalorieGoal))
+	router.GET("user/summary", authentication.Authenticate(fooditemservice.GetUserSummary))
+
+	router.GET("user/meal", authentication.Authenticate(mealservice.GetMeals))
+	router.POST("user/meal", authentication.Authenticate(mealservice.PostMeal))
+	router.DELETE("user/meal/:id", authentication.Authenticate(mealservice.DeleteMeal))
 
 	router.GET("user/food", authentication.Authenticate(fooditemservice.GetUserFoods))
+	router.GET("user/food/export", authentication.Authenticate(fooditemservice.GetUserFoodsExport))
+	router.POST("user/food/import", authentication.Authenticate(fooditemservice.PostUserFoodImport))
 	router.GET("user/food/:id", authentication.Authenticate(fooditemservice.GetUserFood))
 	router.POST("user/food", authentication.Authenticate(fooditemservice.PostUserFood))
 	router.PUT("user/food/:id", authentication.Authenticate(fooditemservice.PutUserFood))
 	router.DELETE("user/food/:id", authentication.Authenticate(fooditemservice.DeleteUserFood))
 
+	router.GET("user/measurement", authentication.Authenticate(measurementservice.GetMeasurements))
+	router.GET("user/measurement/trend", authentication.Authenticate(measurementservice.GetMeasurementTrend))
+	router.GET("user/measurement/:id", authentication.Authenticate(measurementservice.GetMeasurement))
+	router.POST("user/measurement", authentication.Authenticate(measurementservice.PostMeasurement))
+	router.PUT("user/measurement/:id", authentication.Authenticate(measurementservice.PutMeasurement))
+	router.DELETE("user/measurement/:id", authentication.Authenticate(measurementservice.DeleteMeasurement))
+
 	return router
 }
 
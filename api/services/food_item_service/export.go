@@ -0,0 +1,218 @@
+package fooditemservice
+
+import (
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/authentication"
+	"diet-app-backend/util/tokens"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+)
+
+var exportContentTypes = map[string]string{
+	exportCSV:  "text/csv; charset=utf-8",
+	exportJSON: "application/json; charset=utf-8",
+}
+
+var exportHeader = []string{
+	"id", "timestamp", "meal", "food_id", "name", "quantity", "portion",
+	"calories", "protein", "carbohydrates", "fat", "fiber", "sugar", "sodium",
+	"total_calories", "total_protein", "total_carbohydrates", "total_fat", "total_fiber", "total_sugar", "total_sodium",
+}
+
+// GetUserFoodsExport downloads the diary entries of the user in the same
+// range as GetUserFoods. Rows are written as they are read from the database,
+// so that long histories are never held in memory.
+func GetUserFoodsExport(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	format := c.DefaultQuery("format", exportCSV)
+	contentType, ok := exportContentTypes[format]
+
+	if !ok {
+		apierrors.Respond(c, http.StatusBadRequest, "The format query string must be one of csv, json")
+		return
+	}
+
+	location := authentication.GetUser(c).Location()
+	from, to, ok := getRange(c, location)
+
+	if !ok {
+		return
+	}
+
+	userId := claims["id"]
+
+	rows, err := connection.Db.Model(&models.FoodItem{}).
+		Select(joinedFoodItemColumns).
+		Joins("JOIN foods ON food_items.food_id = foods.id").
+		Where("food_items.user_id = ? AND food_items.timestamp >= ? AND food_items.timestamp < ?", userId, from, to).
+		Order("food_items.timestamp, food_items.id").
+		Rows()
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the food items")
+		return
+	}
+	defer rows.Close()
+
+	// to is excluded from the range, so the file is named after the day before
+	lastDay := to.Add(-time.Nanosecond).In(location)
+	filename := fmt.Sprintf("diary-%s-%s.%s", from.In(location).Format(time.DateOnly), lastDay.Format(time.DateOnly), format)
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	next := func() (*schemas.JoinedFoodItem, error) {
+		if !rows.Next() {
+			return nil, rows.Err()
+		}
+
+		var foodItem schemas.JoinedFoodItem
+
+		if err := connection.Db.ScanRows(rows, &foodItem); err != nil {
+			return nil, err
+		}
+
+		foodItem.Timestamp = foodItem.Timestamp.In(location)
+		foodItem.ComputeTotals()
+
+		return &foodItem, nil
+	}
+
+	if format == exportJSON {
+		err = writeJSONExport(c.Writer, next)
+	} else {
+		err = writeCSVExport(c.Writer, next)
+	}
+
+	// The status is already sent, so a failure can only cut the file short
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+func writeCSVExport(w io.Writer, next func() (*schemas.JoinedFoodItem, error)) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(exportHeader); err != nil {
+		return err
+	}
+
+	for {
+		foodItem, err := next()
+
+		if err != nil {
+			return err
+		}
+
+		if foodItem == nil {
+			break
+		}
+
+		if err := writer.Write(exportRecord(foodItem)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func exportRecord(foodItem *schemas.JoinedFoodItem) []string {
+	formatFloat := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	return []string{
+		strconv.FormatUint(uint64(foodItem.ID), 10),
+		foodItem.Timestamp.Format(time.RFC3339),
+		escapeCell(foodItem.Meal),
+		strconv.FormatUint(uint64(foodItem.FoodID), 10),
+		escapeCell(foodItem.Name),
+		strconv.FormatUint(uint64(foodItem.Quantity), 10),
+		strconv.Itoa(foodItem.Portion),
+		strconv.Itoa(foodItem.Calories),
+		formatFloat(foodItem.Protein),
+		formatFloat(foodItem.Carbohydrates),
+		formatFloat(foodItem.Fat),
+		formatFloat(foodItem.Fiber),
+		formatFloat(foodItem.Sugar),
+		formatFloat(foodItem.Sodium),
+		formatFloat(foodItem.Totals.Calories),
+		formatFloat(foodItem.Totals.Protein),
+		formatFloat(foodItem.Totals.Carbohydrates),
+		formatFloat(foodItem.Totals.Fat),
+		formatFloat(foodItem.Totals.Fiber),
+		formatFloat(foodItem.Totals.Sugar),
+		formatFloat(foodItem.Totals.Sodium),
+	}
+}
+
+// escapeCell prefixes text that spreadsheets would run as a formula with a
+// quote, as food names are written by users and the file is meant to be
+// opened in a spreadsheet.
+func escapeCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// writeJSONExport writes a JSON array one element at a time, as encoding/json
+// can only encode a slice that is entirely in memory.
+func writeJSONExport(w io.Writer, next func() (*schemas.JoinedFoodItem, error)) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	for first := true; ; first = false {
+		foodItem, err := next()
+
+		if err != nil {
+			return err
+		}
+
+		if foodItem == nil {
+			break
+		}
+
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(foodItem)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "]")
+
+	return err
+}
@@ -1,45 +1,233 @@
 package fooditemservice
 
 import (
+	goalservice "diet-app-backend/api/services/goal_service"
+	mealservice "diet-app-backend/api/services/meal_service"
 	"diet-app-backend/database/connection"
 	"diet-app-backend/database/models"
 	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/authentication"
+	"diet-app-backend/util/dates"
 	"diet-app-backend/util/tokens"
-	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const joinedFoodItemColumns = "food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, " +
+	"foods.protein, foods.carbohydrates, foods.fat, foods.fiber, foods.sugar, foods.sodium, food_items.quantity, food_items.timestamp, food_items.meal"
+
+// GetUserFoods returns the diary entries of the user between the from and to
+// query strings. Both take a date or a timestamp, dates being interpreted in
+// the timezone of the user and to including the whole day. The legacy
+// timestamp query string is the start of a one day range. Without any of
+// them, the entries of the current day are returned.
 func GetUserFoods(c *gin.Context) {
 	claims, _ := tokens.GetClaims(c)
 
-	now := time.Now()
-	defaultDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from, to, ok := getRange(c, authentication.GetUser(c).Location())
+
+	if !ok {
+		return
+	}
+
+	userId := claims["id"]
+
+	query := connection.Db.Model(&models.FoodItem{}).
+		Select(joinedFoodItemColumns).
+		Joins("JOIN foods ON food_items.food_id = foods.id").
+		Where("food_items.user_id = ? AND food_items.timestamp >= ? AND food_items.timestamp < ?", userId, from, to)
+
+	if meal := c.Query("meal"); meal != "" {
+		query = query.Where("food_items.meal = ?", meal)
+	}
+
+	var foodItems []schemas.JoinedFoodItem
+
+	if err := query.Find(&foodItems).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the food items")
+		return
+	}
 
-	timestampStr := c.DefaultQuery("timestamp", defaultDate.Format(time.RFC3339))
-	timestamp, err := time.Parse(time.RFC3339, timestampStr)
+	foodIds := []uint{}
+
+	for _, foodItem := range foodItems {
+		foodIds = append(foodIds, foodItem.FoodID)
+	}
+
+	nutrients, err := nutrientsOf(foodIds...)
 
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{
-			"error": "The timestamp query string is formatted badly",
-		})
+		apierrors.Internal(c, err, "Failed to retrieve the nutrients")
 		return
 	}
 
-	timestampDayAfter := timestamp.AddDate(0, 0, 1)
+	for i := range foodItems {
+		foodItems[i].Nutrients = nutrients[foodItems[i].FoodID]
+		foodItems[i].ComputeTotals()
+	}
+
+	switch c.Query("group") {
+	case "":
+		c.IndentedJSON(http.StatusOK, foodItems)
+	case "meal":
+		c.IndentedJSON(http.StatusOK, groupByMeal(foodItems))
+	default:
+		apierrors.Respond(c, http.StatusBadRequest, "The entries can only be grouped by meal")
+	}
+}
+
+// getRange reads the range of GetUserFoods from the query strings, responding
+// with an error and returning false when it is invalid.
+func getRange(c *gin.Context, location *time.Location) (from time.Time, to time.Time, ok bool) {
+	fromStr, toStr := c.Query("from"), c.Query("to")
+
+	if fromStr == "" && toStr == "" {
+		if timestampStr, exists := c.GetQuery("timestamp"); exists {
+			timestamp, err := time.Parse(time.RFC3339, timestampStr)
+
+			if err != nil {
+				apierrors.Respond(c, http.StatusBadRequest, "The timestamp query string is formatted badly")
+				return from, to, false
+			}
+
+			// AddDate in the location of the user keeps the same wall
+			// clock time on the next day, even across a DST transition
+			timestamp = timestamp.In(location)
+
+			return timestamp, timestamp.AddDate(0, 0, 1), true
+		}
+	}
+
+	today := dates.StartOfDay(time.Now(), location)
+
+	from, to, err := dates.ParseRange(fromStr, toStr, location, today, func(from time.Time) time.Time {
+		return dates.NextDay(dates.StartOfDay(from, location))
+	})
+
+	if err != nil {
+		apierrors.Respond(c, http.StatusBadRequest, err.Error())
+		return from, to, false
+	}
+
+	return from, to, true
+}
+
+// nutrientsOf returns the micronutrients of the given foods by food id.
+func nutrientsOf(foodIds ...uint) (map[uint][]models.FoodNutrient, error) {
+	nutrients := map[uint][]models.FoodNutrient{}
+
+	if len(foodIds) == 0 {
+		return nutrients, nil
+	}
+
+	var foodNutrients []models.FoodNutrient
+
+	if err := connection.Db.Where("food_id IN ?", foodIds).Order("id").Find(&foodNutrients).Error; err != nil {
+		return nil, err
+	}
+
+	for _, foodNutrient := range foodNutrients {
+		nutrients[foodNutrient.FoodID] = append(nutrients[foodNutrient.FoodID], foodNutrient)
+	}
+
+	return nutrients, nil
+}
+
+// groupByMeal splits diary entries by meal. The fixed meals come first in the
+// order they are eaten, followed by custom meals in the order they appear.
+func groupByMeal(foodItems []schemas.JoinedFoodItem) []schemas.MealFoodItems {
+	groups := []schemas.MealFoodItems{}
+	indexes := map[string]int{}
+
+	for _, meal := range models.Meals {
+		indexes[meal] = len(groups)
+		groups = append(groups, schemas.MealFoodItems{Meal: meal, Items: []schemas.JoinedFoodItem{}})
+	}
+
+	for _, foodItem := range foodItems {
+		index, ok := indexes[foodItem.Meal]
+
+		if !ok {
+			index = len(groups)
+			indexes[foodItem.Meal] = index
+			groups = append(groups, schemas.MealFoodItems{Meal: foodItem.Meal, Items: []schemas.JoinedFoodItem{}})
+		}
+
+		groups[index].Items = append(groups[index].Items, foodItem)
+	}
+
+	return groups
+}
+
+// GetUserSummary compares what the user ate on a day with the calorie goal
+// that was in force on that day.
+func GetUserSummary(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	location := authentication.GetUser(c).Location()
+	date := dates.StartOfDay(time.Now(), location)
+
+	if dateStr := c.Query("date"); dateStr != "" {
+		var err error
+
+		if date, err = dates.ParseDay(dateStr, location); err != nil {
+			apierrors.Respond(c, http.StatusBadRequest, "The date query string is formatted badly")
+			return
+		}
+	}
+
+	dayAfter := dates.NextDay(date)
 
 	userId := claims["id"]
 
 	var foodItems []schemas.JoinedFoodItem
-	connection.Db.Model(&models.FoodItem{}).
-		Select("food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp").
+
+	err := connection.Db.Model(&models.FoodItem{}).
+		Select(joinedFoodItemColumns).
 		Joins("JOIN foods ON food_items.food_id = foods.id").
-		Where("user_id = ? AND timestamp BETWEEN ? AND ?", userId, timestamp, timestampDayAfter).
-		Find(&foodItems)
+		Where("food_items.user_id = ? AND food_items.timestamp >= ? AND food_items.timestamp < ?", userId, date, dayAfter).
+		Find(&foodItems).Error
 
-	c.IndentedJSON(http.StatusOK, foodItems)
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the food items")
+		return
+	}
+
+	goal, err := goalservice.GoalBefore(connection.Db, userId, dayAfter)
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the calorie goal")
+		return
+	}
+
+	for i := range foodItems {
+		foodItems[i].ComputeTotals()
+	}
+
+	summary := schemas.DailySummary{Date: date.Format(time.DateOnly)}
+
+	for _, group := range groupByMeal(foodItems) {
+		mealSummary := schemas.MealSummary{Meal: group.Meal}
+
+		for _, foodItem := range group.Items {
+			mealSummary.Calories += foodItem.Totals.Calories
+		}
+
+		summary.Consumed += mealSummary.Calories
+		summary.Meals = append(summary.Meals, mealSummary)
+	}
+
+	if goal != nil {
+		remaining := float64(goal.Calories) - summary.Consumed
+
+		summary.Goal = &goal.Calories
+		summary.Remaining = &remaining
+	}
+
+	c.IndentedJSON(http.StatusOK, summary)
 }
 
 func GetUserFood(c *gin.Context) {
@@ -50,18 +238,26 @@ func GetUserFood(c *gin.Context) {
 
 	var foodItem schemas.JoinedFoodItem
 	result := connection.Db.Model(&models.FoodItem{}).
-		Select("food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp").
+		Select(joinedFoodItemColumns).
 		Joins("JOIN foods ON food_items.food_id = foods.id").
 		Where("food_items.id = ? AND food_items.user_id = ?", id, userId).
 		First(&foodItem)
 
 	if result.Error != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{
-			"error": "Not found",
-		})
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	nutrients, err := nutrientsOf(foodItem.FoodID)
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the nutrients")
 		return
 	}
 
+	foodItem.Nutrients = nutrients[foodItem.FoodID]
+	foodItem.ComputeTotals()
+
 	c.IndentedJSON(http.StatusOK, foodItem)
 }
 
@@ -72,29 +268,49 @@ func PostUserFood(c *gin.Context) {
 
 	var foodItem models.FoodItem
 
-	if err := c.BindJSON(&foodItem); err != nil {
+	if !apierrors.BindJSON(c, &foodItem) {
 		return
 	}
 
 	foodItem.UserID = uint(userId.(float64))
 
-	result := connection.Db.Create(&foodItem)
+	if foodItem.Meal == "" {
+		foodItem.Meal = models.MealAt(foodItem.Timestamp.In(authentication.GetUser(c).Location()))
+	} else if !checkMeal(c, userId, foodItem.Meal) {
+		return
+	}
 
-	if result.Error != nil {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{
-			"error": "A food item entry could not be created",
-		})
+	// Custom foods of other users can't be logged
+	var food models.Food
+
+	if err := connection.Db.Scopes(models.VisibleFoods(foodItem.UserID)).First(&food, foodItem.FoodID).Error; err != nil {
+		apierrors.Respond(c, http.StatusBadRequest, "The food does not exist")
+		return
+	}
+
+	if err := connection.Db.Create(&foodItem).Error; err != nil {
+		apierrors.Internal(c, err, "A food item entry could not be created")
 		return
 	}
 
 	var joinedFoodItem schemas.JoinedFoodItem
 
 	connection.Db.Model(&models.FoodItem{}).
-		Select("food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp").
+		Select(joinedFoodItemColumns).
 		Joins("JOIN foods ON food_items.food_id = foods.id").
 		Where("food_items.id = ? AND food_items.user_id = ?", foodItem.ID, userId).
 		First(&joinedFoodItem)
 
+	// The entry is saved, so the response goes without the nutrients if
+	// they can't be retrieved
+	if nutrients, err := nutrientsOf(joinedFoodItem.FoodID); err != nil {
+		c.Error(err)
+	} else {
+		joinedFoodItem.Nutrients = nutrients[joinedFoodItem.FoodID]
+	}
+
+	joinedFoodItem.ComputeTotals()
+
 	c.IndentedJSON(http.StatusCreated, joinedFoodItem)
 }
 
@@ -106,8 +322,7 @@ func PutUserFood(c *gin.Context) {
 
 	var updateFoodItem schemas.UpdateFoodItem
 
-	if err := c.BindJSON(&updateFoodItem); err != nil {
-		fmt.Println(err)
+	if !apierrors.BindJSON(c, &updateFoodItem) {
 		return
 	}
 
@@ -115,36 +330,67 @@ func PutUserFood(c *gin.Context) {
 	result := connection.Db.Where("id = ? AND user_id = ?", id, userId).First(&foodItem)
 
 	if result.Error != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{
-			"error": "Not found",
-		})
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
 		return
 	}
 
+	if updateFoodItem.Meal != "" {
+		if !checkMeal(c, userId, updateFoodItem.Meal) {
+			return
+		}
+
+		foodItem.Meal = updateFoodItem.Meal
+	}
+
 	foodItem.Quantity = updateFoodItem.Quantity
 	foodItem.Timestamp = updateFoodItem.Timestamp
 
 	updateResult := connection.Db.Save(&foodItem)
 
 	if updateResult.Error != nil {
-		fmt.Println("updateResult.Error", updateResult.Error)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update record",
-		})
+		apierrors.Internal(c, updateResult.Error, "Failed to update record")
 		return
 	}
 
 	var joinedFoodItem schemas.JoinedFoodItem
 
 	connection.Db.Model(&models.FoodItem{}).
-		Select("food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp").
+		Select(joinedFoodItemColumns).
 		Joins("JOIN foods ON food_items.food_id = foods.id").
 		Where("food_items.id = ? AND food_items.user_id = ?", id, userId).
 		First(&joinedFoodItem)
 
+	// The entry is saved, so the response goes without the nutrients if
+	// they can't be retrieved
+	if nutrients, err := nutrientsOf(joinedFoodItem.FoodID); err != nil {
+		c.Error(err)
+	} else {
+		joinedFoodItem.Nutrients = nutrients[joinedFoodItem.FoodID]
+	}
+
+	joinedFoodItem.ComputeTotals()
+
 	c.IndentedJSON(http.StatusOK, joinedFoodItem)
 }
 
+// checkMeal responds with an error and returns false when the meal is
+// neither a fixed meal nor a custom meal of the user.
+func checkMeal(c *gin.Context, userId any, meal string) bool {
+	exists, err := mealservice.MealExists(connection.Db, userId, meal)
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the meal")
+		return false
+	}
+
+	if !exists {
+		apierrors.Respond(c, http.StatusBadRequest, "The meal does not exist")
+		return false
+	}
+
+	return true
+}
+
 func DeleteUserFood(c *gin.Context) {
 	claims, _ := tokens.GetClaims(c)
 
@@ -155,12 +401,14 @@ func DeleteUserFood(c *gin.Context) {
 	result := connection.Db.Where("id = ? AND user_id = ?", id, userId).First(&foodItem)
 
 	if result.Error != nil {
-		c.IndentedJSON(http.StatusNotFound, gin.H{
-			"error": "Not found",
-		})
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if err := connection.Db.Delete(&foodItem).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to delete record")
 		return
 	}
 
-	connection.Db.Delete(&foodItem)
 	c.IndentedJSON(http.StatusNoContent, nil)
 }
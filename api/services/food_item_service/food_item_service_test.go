@@ -1,7 +1,9 @@
 package fooditemservice_test
 
 import (
+	"bytes"
 	"database/sql"
+	"database/sql/driver"
 	"diet-app-backend/api/routes"
 	"diet-app-backend/database/connection"
 	"diet-app-backend/database/models"
@@ -9,8 +11,11 @@ import (
 	"diet-app-backend/util/config"
 	"diet-app-backend/util/hashing"
 	"diet-app-backend/util/tests"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -73,6 +78,8 @@ func (suite *TestSuite) TestGetUserFoodsSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -80,10 +87,13 @@ func (suite *TestSuite) TestGetUserFoodsSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	suite.mock.ExpectQuery(
-		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp"+
+		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, "+
+			"foods.protein, foods.carbohydrates, foods.fat, foods.fiber, foods.sugar, foods.sodium, food_items.quantity, food_items.timestamp, food_items.meal"+
 			" FROM `food_items` "+
-			"JOIN foods ON food_items.food_id = foods.id WHERE user_id = \\? AND timestamp BETWEEN \\? AND \\?",
+			"JOIN foods ON food_items.food_id = foods.id WHERE food_items.user_id = \\? AND food_items.timestamp >= \\? AND food_items.timestamp < \\?",
 	).
 		WithArgs(float64(1), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(
@@ -92,6 +102,7 @@ func (suite *TestSuite) TestGetUserFoodsSuccessful() {
 				AddRow(1, 1, 2, "Grated Cheese", 492, 100, 150, time.Now()).
 				AddRow(1, 1, 3, "Tomato Sauce", 34, 100, 50, time.Now()),
 		)
+	suite.expectNutrients(1, 2, 3)
 
 	router := routes.SetupRouter()
 	w := httptest.NewRecorder()
@@ -147,7 +158,7 @@ func (suite *TestSuite) TestGetUserFoodsWithoutAuthorization() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 403, w.Code)
-	assert.Equal(suite.T(), "Authentication failed", responseBody.Error)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
 }
 
 func (suite *TestSuite) TestGetUserFoodsWithTimestampFilter() {
@@ -160,6 +171,8 @@ func (suite *TestSuite) TestGetUserFoodsWithTimestampFilter() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -167,16 +180,20 @@ func (suite *TestSuite) TestGetUserFoodsWithTimestampFilter() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	suite.mock.ExpectQuery(
-		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp"+
+		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, "+
+			"foods.protein, foods.carbohydrates, foods.fat, foods.fiber, foods.sugar, foods.sodium, food_items.quantity, food_items.timestamp, food_items.meal"+
 			" FROM `food_items` "+
-			"JOIN foods ON food_items.food_id = foods.id WHERE user_id = \\? AND timestamp BETWEEN \\? AND \\?",
+			"JOIN foods ON food_items.food_id = foods.id WHERE food_items.user_id = \\? AND food_items.timestamp >= \\? AND food_items.timestamp < \\?",
 	).
 		WithArgs(float64(1), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(
 			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp"}).
 				AddRow(1, 1, 1, "Pasta", 193, 80, 100, time.Now()),
 		)
+	suite.expectNutrients(1)
 
 	router := routes.SetupRouter()
 	w := httptest.NewRecorder()
@@ -204,6 +221,17 @@ func (suite *TestSuite) TestGetUserFoodsWithTimestampFilter() {
 	assert.Equal(suite.T(), uint(100), responseBody[0].Quantity)
 }
 
+// expectNutrients expects the micronutrients of the foods of diary entries
+// to be retrieved, returning none unless rows are given to the expectation.
+func (suite *TestSuite) expectNutrients(foodIds ...driver.Value) *sqlmock.ExpectedQuery {
+	query := suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients` WHERE food_id IN \\(.*\\) ORDER BY id").
+		WithArgs(foodIds...)
+
+	query.WillReturnRows(sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}))
+
+	return query
+}
+
 func (suite *TestSuite) TestGetUserFoodSuccessful() {
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(email, 1).
@@ -212,6 +240,8 @@ func (suite *TestSuite) TestGetUserFoodSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -219,15 +249,24 @@ func (suite *TestSuite) TestGetUserFoodSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	suite.mock.ExpectQuery(
-		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp"+
+		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, "+
+			"foods.protein, foods.carbohydrates, foods.fat, foods.fiber, foods.sugar, foods.sodium, food_items.quantity, food_items.timestamp, food_items.meal"+
 			" FROM `food_items` "+
 			"JOIN foods ON food_items.food_id = foods.id WHERE food_items.id = \\? AND food_items.user_id = \\? ORDER BY `food_items`.`id` LIMIT \\?",
 	).
 		WithArgs("1", float64(1), 1).
 		WillReturnRows(
-			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp"}).
-				AddRow(1, 1, 1, "Pasta", 193, 80, 100, time.Now()),
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "protein", "carbohydrates", "fat", "fiber", "sugar", "sodium", "quantity", "timestamp"}).
+				AddRow(1, 1, 1, "Pasta", 193, 80, 5.6, 38.4, 0.8, 2.4, 0.8, 4, 100, time.Now()),
+		)
+	suite.expectNutrients(1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}).
+				AddRow(1, 1, "Vitamin B1", 0.08, "mg").
+				AddRow(2, 1, "Iron", 1.2, "mg"),
 		)
 
 	router := routes.SetupRouter()
@@ -251,7 +290,26 @@ func (suite *TestSuite) TestGetUserFoodSuccessful() {
 	assert.Equal(suite.T(), "Pasta", responseBody.Name)
 	assert.Equal(suite.T(), 193, responseBody.Calories)
 	assert.Equal(suite.T(), 80, responseBody.Portion)
+	assert.Equal(suite.T(), 5.6, responseBody.Protein)
+	assert.Equal(suite.T(), 38.4, responseBody.Carbohydrates)
 	assert.Equal(suite.T(), uint(100), responseBody.Quantity)
+
+	assert.InDelta(suite.T(), 241.25, responseBody.Totals.Calories, 0.001)
+	assert.InDelta(suite.T(), 7, responseBody.Totals.Protein, 0.001)
+	assert.InDelta(suite.T(), 48, responseBody.Totals.Carbohydrates, 0.001)
+	assert.InDelta(suite.T(), 1, responseBody.Totals.Fat, 0.001)
+	assert.InDelta(suite.T(), 3, responseBody.Totals.Fiber, 0.001)
+	assert.InDelta(suite.T(), 1, responseBody.Totals.Sugar, 0.001)
+	assert.InDelta(suite.T(), 5, responseBody.Totals.Sodium, 0.001)
+
+	assert.Equal(suite.T(), []models.FoodNutrient{
+		{Name: "Vitamin B1", Amount: 0.08, Unit: "mg"},
+		{Name: "Iron", Amount: 1.2, Unit: "mg"},
+	}, responseBody.Nutrients)
+	assert.Len(suite.T(), responseBody.Totals.Nutrients, 2)
+	assert.Equal(suite.T(), "Vitamin B1", responseBody.Totals.Nutrients[0].Name)
+	assert.InDelta(suite.T(), 0.1, responseBody.Totals.Nutrients[0].Amount, 0.001)
+	assert.InDelta(suite.T(), 1.5, responseBody.Totals.Nutrients[1].Amount, 0.001)
 }
 
 func (suite *TestSuite) TestGetUserFoodWithoutAuthorization() {
@@ -266,7 +324,7 @@ func (suite *TestSuite) TestGetUserFoodWithoutAuthorization() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 403, w.Code)
-	assert.Equal(suite.T(), "Authentication failed", responseBody.Error)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
 }
 
 func (suite *TestSuite) TestGetUserFoodNotFound() {
@@ -277,6 +335,8 @@ func (suite *TestSuite) TestGetUserFoodNotFound() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -284,8 +344,11 @@ func (suite *TestSuite) TestGetUserFoodNotFound() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	suite.mock.ExpectQuery(
-		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp"+
+		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, "+
+			"foods.protein, foods.carbohydrates, foods.fat, foods.fiber, foods.sugar, foods.sodium, food_items.quantity, food_items.timestamp, food_items.meal"+
 			" FROM `food_items` "+
 			"JOIN foods ON food_items.food_id = foods.id WHERE food_items.id = \\? AND food_items.user_id = \\? ORDER BY `food_items`.`id` LIMIT \\?",
 	).
@@ -308,10 +371,12 @@ func (suite *TestSuite) TestGetUserFoodNotFound() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 404, w.Code)
-	assert.Equal(suite.T(), "Not found", responseBody.Error)
+	assert.Equal(suite.T(), "Not found", responseBody.Message)
 }
 
 func (suite *TestSuite) TestPostUserFoodSuccessful() {
+	timestamp := time.Now()
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(email, 1).
 		WillReturnRows(
@@ -319,6 +384,8 @@ func (suite *TestSuite) TestPostUserFoodSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -326,14 +393,24 @@ func (suite *TestSuite) TestPostUserFoodSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE `foods`.`id` = \\? AND foods.user_id IN \\(\\?,\\?\\) ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(1, 0, 1, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(1, 0, "Pasta", 193, 80),
+		)
+
 	suite.mock.ExpectBegin()
 	suite.mock.ExpectExec("INSERT INTO `food_items`").
-		WithArgs(1, 1, 100, sqlmock.AnyArg()).
+		WithArgs(1, 1, 100, sqlmock.AnyArg(), models.MealAt(timestamp.UTC())).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	suite.mock.ExpectCommit()
 
 	suite.mock.ExpectQuery(
-		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp"+
+		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, "+
+			"foods.protein, foods.carbohydrates, foods.fat, foods.fiber, foods.sugar, foods.sodium, food_items.quantity, food_items.timestamp, food_items.meal"+
 			" FROM `food_items` "+
 			"JOIN foods ON food_items.food_id = foods.id WHERE food_items.id = \\? AND food_items.user_id = \\? ORDER BY `food_items`.`id` LIMIT \\?",
 	).
@@ -342,6 +419,7 @@ func (suite *TestSuite) TestPostUserFoodSuccessful() {
 			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp"}).
 				AddRow(1, 1, 1, "Pasta", 193, 80, 100, time.Now()),
 		)
+	suite.expectNutrients(1)
 
 	router := routes.SetupRouter()
 	w := httptest.NewRecorder()
@@ -351,7 +429,7 @@ func (suite *TestSuite) TestPostUserFoodSuccessful() {
 	userFoodData := models.FoodItem{
 		FoodID:    1,
 		Quantity:  100,
-		Timestamp: time.Now(),
+		Timestamp: timestamp,
 	}
 	userFoodDataJson, _ := json.Marshal(userFoodData)
 
@@ -374,6 +452,86 @@ func (suite *TestSuite) TestPostUserFoodSuccessful() {
 	assert.Equal(suite.T(), uint(100), responseBody.Quantity)
 }
 
+func (suite *TestSuite) TestPostUserFoodWithDatabaseError() {
+	token := suite.expectAuthentication()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE `foods`.`id` = \\?").
+		WithArgs(1, 0, 1, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(1, 0, "Pasta", 193, 80),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `food_items`").
+		WillReturnError(errors.New("connection lost"))
+	suite.mock.ExpectRollback()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"food_id": 1, "quantity": 100, "timestamp": "2024-05-10T12:00:00Z"}`
+
+	req, _ := http.NewRequest("POST", "/user/food", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "A food item entry could not be created", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostUserFoodWithCustomFoodOfAnotherUser() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE `foods`.`id` = \\? AND foods.user_id IN \\(\\?,\\?\\) ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(2, 0, 1, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	token := tests.GetToken(email, password)
+
+	foodItem := models.FoodItem{
+		FoodID:    2,
+		Quantity:  100,
+		Timestamp: time.Now(),
+	}
+	foodItemJson, _ := json.Marshal(foodItem)
+
+	req, _ := http.NewRequest("POST", "/user/food", strings.NewReader(string(foodItemJson)))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The food does not exist", responseBody.Message)
+}
+
 func (suite *TestSuite) TestPostUserFoodWithoutAuthorization() {
 	router := routes.SetupRouter()
 	w := httptest.NewRecorder()
@@ -393,7 +551,7 @@ func (suite *TestSuite) TestPostUserFoodWithoutAuthorization() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 403, w.Code)
-	assert.Equal(suite.T(), "Authentication failed", responseBody.Error)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
 }
 
 func (suite *TestSuite) TestPostUserFoodRequiresFoodID() {
@@ -404,6 +562,8 @@ func (suite *TestSuite) TestPostUserFoodRequiresFoodID() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -411,6 +571,8 @@ func (suite *TestSuite) TestPostUserFoodRequiresFoodID() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	token := tests.GetToken(email, password)
 
 	router := routes.SetupRouter()
@@ -438,6 +600,8 @@ func (suite *TestSuite) TestPostUserFoodRequiresQuantity() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -445,6 +609,8 @@ func (suite *TestSuite) TestPostUserFoodRequiresQuantity() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	token := tests.GetToken(email, password)
 
 	router := routes.SetupRouter()
@@ -472,6 +638,8 @@ func (suite *TestSuite) TestPostUserFoodRequiresTimestamp() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -479,6 +647,8 @@ func (suite *TestSuite) TestPostUserFoodRequiresTimestamp() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	token := tests.GetToken(email, password)
 
 	router := routes.SetupRouter()
@@ -506,6 +676,8 @@ func (suite *TestSuite) TestPutUserFoodSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -513,6 +685,8 @@ func (suite *TestSuite) TestPutUserFoodSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `food_items` WHERE id = \\? AND user_id = \\? ORDER BY `food_items`.`id` LIMIT \\?").
 		WithArgs("1", float64(1), 1).
 		WillReturnRows(
@@ -525,7 +699,8 @@ func (suite *TestSuite) TestPutUserFoodSuccessful() {
 	suite.mock.ExpectCommit()
 
 	suite.mock.ExpectQuery(
-		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, food_items.quantity, food_items.timestamp"+
+		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, "+
+			"foods.protein, foods.carbohydrates, foods.fat, foods.fiber, foods.sugar, foods.sodium, food_items.quantity, food_items.timestamp, food_items.meal"+
 			" FROM `food_items` "+
 			"JOIN foods ON food_items.food_id = foods.id WHERE food_items.id = \\? AND food_items.user_id = \\? ORDER BY `food_items`.`id` LIMIT \\?",
 	).
@@ -534,6 +709,7 @@ func (suite *TestSuite) TestPutUserFoodSuccessful() {
 			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp"}).
 				AddRow(1, 1, 1, "Pasta", 193, 80, 120, time.Now()),
 		)
+	suite.expectNutrients(1)
 
 	token := tests.GetToken(email, password)
 
@@ -583,7 +759,7 @@ func (suite *TestSuite) TestPutUserFoodWithoutAuthorization() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 403, w.Code)
-	assert.Equal(suite.T(), "Authentication failed", responseBody.Error)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
 }
 
 func (suite *TestSuite) TestPutUserFoodRequiresQuantity() {
@@ -594,6 +770,8 @@ func (suite *TestSuite) TestPutUserFoodRequiresQuantity() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -601,6 +779,8 @@ func (suite *TestSuite) TestPutUserFoodRequiresQuantity() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	token := tests.GetToken(email, password)
 
 	router := routes.SetupRouter()
@@ -627,6 +807,8 @@ func (suite *TestSuite) TestPutUserFoodRequiresTimestamp() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -634,6 +816,8 @@ func (suite *TestSuite) TestPutUserFoodRequiresTimestamp() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	token := tests.GetToken(email, password)
 
 	router := routes.SetupRouter()
@@ -660,6 +844,8 @@ func (suite *TestSuite) TestPutUserFoodItemDoesNotBelongToUser() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -667,6 +853,8 @@ func (suite *TestSuite) TestPutUserFoodItemDoesNotBelongToUser() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `food_items` WHERE id = \\? AND user_id = \\? ORDER BY `food_items`.`id` LIMIT \\?").
 		WithArgs("1", float64(1), 1).
 		WillReturnRows(
@@ -693,7 +881,7 @@ func (suite *TestSuite) TestPutUserFoodItemDoesNotBelongToUser() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 404, w.Code)
-	assert.Equal(suite.T(), "Not found", responseBody.Error)
+	assert.Equal(suite.T(), "Not found", responseBody.Message)
 }
 
 func (suite *TestSuite) TestDeleteUserFoodSuccessful() {
@@ -704,6 +892,8 @@ func (suite *TestSuite) TestDeleteUserFoodSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -711,6 +901,8 @@ func (suite *TestSuite) TestDeleteUserFoodSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `food_items` WHERE id = \\? AND user_id = \\? ORDER BY `food_items`.`id` LIMIT \\?").
 		WithArgs("1", float64(1), 1).
 		WillReturnRows(
@@ -735,6 +927,35 @@ func (suite *TestSuite) TestDeleteUserFoodSuccessful() {
 	assert.Equal(suite.T(), 204, w.Code)
 }
 
+func (suite *TestSuite) TestDeleteUserFoodWithDatabaseError() {
+	token := suite.expectAuthentication()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_items` WHERE id = \\? AND user_id = \\?").
+		WithArgs("1", float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "quantity", "timestamp"}).
+				AddRow(1, 1, 1, 100, time.Now()),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("DELETE FROM `food_items`").
+		WillReturnError(errors.New("connection lost"))
+	suite.mock.ExpectRollback()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/food/1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "Failed to delete record", responseBody.Message)
+}
+
 func (suite *TestSuite) TestDeleteUserFoodWithoutAuthorization() {
 	router := routes.SetupRouter()
 	w := httptest.NewRecorder()
@@ -747,7 +968,7 @@ func (suite *TestSuite) TestDeleteUserFoodWithoutAuthorization() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 403, w.Code)
-	assert.Equal(suite.T(), "Authentication failed", responseBody.Error)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
 }
 
 func (suite *TestSuite) TestDeleteUserFoodNotFound() {
@@ -758,6 +979,8 @@ func (suite *TestSuite) TestDeleteUserFoodNotFound() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -765,6 +988,8 @@ func (suite *TestSuite) TestDeleteUserFoodNotFound() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `food_items` WHERE id = \\? AND user_id = \\? ORDER BY `food_items`.`id` LIMIT \\?").
 		WithArgs("1", float64(1), 1).
 		WillReturnRows(
@@ -785,7 +1010,713 @@ func (suite *TestSuite) TestDeleteUserFoodNotFound() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 404, w.Code)
-	assert.Equal(suite.T(), "Not found", responseBody.Error)
+	assert.Equal(suite.T(), "Not found", responseBody.Message)
+}
+
+func (suite *TestSuite) expectAuthentication() string {
+	return suite.expectAuthenticationIn("UTC")
+}
+
+func (suite *TestSuite) expectAuthenticationIn(timezone string) string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "timezone"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, timezone),
+		)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) TestGetUserSummarySuccessful() {
+	token := suite.expectAuthentication()
+
+	date := time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC)
+
+	suite.mock.ExpectQuery(
+		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, "+
+			"foods.protein, foods.carbohydrates, foods.fat, foods.fiber, foods.sugar, foods.sodium, food_items.quantity, food_items.timestamp, food_items.meal"+
+			" FROM `food_items` "+
+			"JOIN foods ON food_items.food_id = foods.id WHERE food_items.user_id = \\? AND food_items.timestamp >= \\? AND food_items.timestamp < \\?",
+	).
+		WithArgs(float64(1), date, date.AddDate(0, 0, 1)).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp", "meal"}).
+				AddRow(1, 1, 4, "Oatmeal", 380, 100, 50, date.Add(8*time.Hour), models.MealBreakfast).
+				AddRow(2, 1, 1, "Pasta", 193, 80, 160, date.Add(20*time.Hour), models.MealDinner).
+				AddRow(3, 1, 3, "Tomato Sauce", 34, 100, 100, date.Add(20*time.Hour), models.MealDinner).
+				AddRow(4, 1, 5, "Protein Bar", 350, 60, 60, date.Add(17*time.Hour), "pre-workout"),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `calorie_goals` WHERE user_id = \\? AND effective_from < \\? ORDER BY effective_from desc").
+		WithArgs(float64(1), date.AddDate(0, 0, 1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "calories", "effective_from"}).
+				AddRow(1, 1, 2000, date.AddDate(0, -1, 0)),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/summary?date=2024-05-10", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.DailySummary
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), "2024-05-10", responseBody.Date)
+	// 380 * 50 / 100 + 193 * 160 / 80 + 34 * 100 / 100 + 350 * 60 / 60
+	assert.InDelta(suite.T(), 960.0, responseBody.Consumed, 0.001)
+	assert.Equal(suite.T(), uint(2000), *responseBody.Goal)
+	assert.InDelta(suite.T(), 1040.0, *responseBody.Remaining, 0.001)
+	assert.Equal(suite.T(), []schemas.MealSummary{
+		{Meal: models.MealBreakfast, Calories: 190},
+		{Meal: models.MealLunch, Calories: 0},
+		{Meal: models.MealDinner, Calories: 420},
+		{Meal: models.MealSnack, Calories: 0},
+		{Meal: "pre-workout", Calories: 350},
+	}, responseBody.Meals)
+}
+
+func (suite *TestSuite) TestGetUserSummaryWithoutGoal() {
+	token := suite.expectAuthentication()
+
+	suite.mock.ExpectQuery("^SELECT food_items.id").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp"}),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `calorie_goals`").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "calories", "effective_from"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/summary", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.DailySummary
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), time.Now().Format(time.DateOnly), responseBody.Date)
+	assert.Equal(suite.T(), 0.0, responseBody.Consumed)
+	assert.Nil(suite.T(), responseBody.Goal)
+	assert.Nil(suite.T(), responseBody.Remaining)
+}
+
+func (suite *TestSuite) TestGetUserSummaryWithBadDate() {
+	token := suite.expectAuthentication()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/summary?date=10/05/2024", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The date query string is formatted badly", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetUserSummaryWithDatabaseError() {
+	token := suite.expectAuthentication()
+
+	suite.mock.ExpectQuery("^SELECT food_items.id").
+		WillReturnError(errors.New("Error 1052: Column 'user_id' in where clause is ambiguous"))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/summary", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	// The summary doesn't report the whole goal as remaining
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "Failed to retrieve the food items", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetUserFoodsGroupedByMeal() {
+	token := suite.expectAuthentication()
+
+	timestamp := time.Date(2024, time.May, 10, 12, 0, 0, 0, time.UTC)
+
+	suite.mock.ExpectQuery(
+		"^SELECT food_items.id, .* FROM `food_items` "+
+			"JOIN foods ON food_items.food_id = foods.id WHERE \\(food_items.user_id = \\? AND food_items.timestamp >= \\? AND food_items.timestamp < \\?\\) AND food_items.meal = \\?",
+	).
+		WithArgs(float64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), "pre-workout").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp", "meal"}).
+				AddRow(4, 1, 5, "Protein Bar", 350, 60, 60, timestamp, "pre-workout"),
+		)
+	suite.expectNutrients(5)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food?meal=pre-workout&group=meal", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody []schemas.MealFoodItems
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Len(suite.T(), responseBody, 5)
+
+	for i, meal := range models.Meals {
+		assert.Equal(suite.T(), meal, responseBody[i].Meal)
+		assert.Empty(suite.T(), responseBody[i].Items)
+	}
+
+	assert.Equal(suite.T(), "pre-workout", responseBody[4].Meal)
+	assert.Len(suite.T(), responseBody[4].Items, 1)
+	assert.Equal(suite.T(), "Protein Bar", responseBody[4].Items[0].Name)
+}
+
+func (suite *TestSuite) TestGetUserFoodsWithDatabaseError() {
+	token := suite.expectAuthentication()
+
+	suite.mock.ExpectQuery("^SELECT food_items.id").
+		WillReturnError(errors.New("Error 1052: Column 'user_id' in where clause is ambiguous"))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "Failed to retrieve the food items", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetUserFoodsWithUnknownGrouping() {
+	token := suite.expectAuthentication()
+
+	suite.mock.ExpectQuery("^SELECT food_items.id").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp", "meal"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food?group=food", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The entries can only be grouped by meal", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostUserFoodWithCustomMeal() {
+	token := suite.expectAuthentication()
+
+	timestamp := time.Date(2024, time.May, 10, 17, 0, 0, 0, time.UTC)
+
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `custom_meals` WHERE user_id = \\? AND name = \\?").
+		WithArgs(float64(1), "pre-workout").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods`").
+		WithArgs(5, 0, 1, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(5, 0, "Protein Bar", 350, 60),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `food_items`").
+		WithArgs(1, 5, 60, timestamp, "pre-workout").
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	suite.mock.ExpectCommit()
+	suite.mock.ExpectQuery("^SELECT food_items.id").
+		WithArgs(4, float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp", "meal"}).
+				AddRow(4, 1, 5, "Protein Bar", 350, 60, 60, timestamp, "pre-workout"),
+		)
+	suite.expectNutrients(5)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/food", strings.NewReader(
+		`{"food_id": 5, "quantity": 60, "timestamp": "2024-05-10T17:00:00Z", "meal": "pre-workout"}`,
+	))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.JoinedFoodItem
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 201, w.Code)
+	assert.Equal(suite.T(), "pre-workout", responseBody.Meal)
+}
+
+func (suite *TestSuite) TestPostUserFoodWithUnknownMeal() {
+	token := suite.expectAuthentication()
+
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `custom_meals` WHERE user_id = \\? AND name = \\?").
+		WithArgs(float64(1), "brunch").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/food", strings.NewReader(
+		`{"food_id": 1, "quantity": 100, "timestamp": "2024-05-10T10:00:00Z", "meal": "brunch"}`,
+	))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The meal does not exist", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetUserFoodsWithRangeInUserTimezone() {
+	token := suite.expectAuthenticationIn("America/New_York")
+
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	suite.mock.ExpectQuery(
+		"^SELECT food_items.id, .* FROM `food_items` "+
+			"JOIN foods ON food_items.food_id = foods.id WHERE food_items.user_id = \\? AND food_items.timestamp >= \\? AND food_items.timestamp < \\?",
+	).
+		WithArgs(
+			float64(1),
+			time.Date(2024, time.March, 9, 0, 0, 0, 0, newYork),
+			// The 10th is only 23 hours long in New York
+			time.Date(2024, time.March, 11, 0, 0, 0, 0, newYork),
+		).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp", "meal"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food?from=2024-03-09&to=2024-03-10", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 200, w.Code)
+}
+
+func (suite *TestSuite) TestGetUserFoodsWithTimestampRange() {
+	token := suite.expectAuthentication()
+
+	suite.mock.ExpectQuery("^SELECT food_items.id").
+		WithArgs(
+			float64(1),
+			time.Date(2024, time.March, 9, 6, 0, 0, 0, time.UTC),
+			time.Date(2024, time.March, 9, 12, 0, 0, 0, time.UTC),
+		).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp", "meal"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food?from=2024-03-09T06:00:00Z&to=2024-03-09T12:00:00Z", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 200, w.Code)
+}
+
+func (suite *TestSuite) TestGetUserFoodsWithReversedRange() {
+	token := suite.expectAuthentication()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food?from=2024-03-10&to=2024-03-09", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The range must end after it starts", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetUserFoodsWithBadFrom() {
+	token := suite.expectAuthentication()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food?from=yesterday", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The from query string is formatted badly", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetUserSummaryOnDstTransition() {
+	token := suite.expectAuthenticationIn("Europe/Paris")
+
+	paris, _ := time.LoadLocation("Europe/Paris")
+
+	suite.mock.ExpectQuery("^SELECT food_items.id").
+		WithArgs(
+			float64(1),
+			time.Date(2024, time.October, 27, 0, 0, 0, 0, paris),
+			time.Date(2024, time.October, 28, 0, 0, 0, 0, paris),
+		).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp", "meal"}),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `calorie_goals`").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "calories", "effective_from"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/summary?date=2024-10-27", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 200, w.Code)
+}
+
+func (suite *TestSuite) expectExportQuery(from time.Time, to time.Time) {
+	suite.mock.ExpectQuery(
+		"^SELECT food_items.id, food_items.user_id, foods.id as food_id, foods.name, foods.calories, foods.portion, "+
+			"foods.protein, foods.carbohydrates, foods.fat, foods.fiber, foods.sugar, foods.sodium, food_items.quantity, food_items.timestamp, food_items.meal"+
+			" FROM `food_items` "+
+			"JOIN foods ON food_items.food_id = foods.id WHERE food_items.user_id = \\? AND food_items.timestamp >= \\? AND food_items.timestamp < \\? "+
+			"ORDER BY food_items.timestamp, food_items.id",
+	).
+		WithArgs(float64(1), from, to).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "protein", "quantity", "timestamp", "meal"}).
+				AddRow(1, 1, 1, "Pasta", 193, 80, 7.5, 160, from.Add(20*time.Hour), models.MealDinner).
+				AddRow(2, 1, 4, "Oatmeal, rolled", 380, 100, 13.0, 50, from.AddDate(0, 0, 1).Add(8*time.Hour), models.MealBreakfast),
+		)
+}
+
+func (suite *TestSuite) TestGetUserFoodsExportAsCSV() {
+	token := suite.expectAuthentication()
+
+	from := time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC)
+	suite.expectExportQuery(from, from.AddDate(0, 0, 2))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food/export?from=2024-05-10&to=2024-05-11", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(suite.T(), `attachment; filename="diary-2024-05-10-2024-05-11.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(suite.T(),
+		"id,timestamp,meal,food_id,name,quantity,portion,calories,protein,carbohydrates,fat,fiber,sugar,sodium,"+
+			"total_calories,total_protein,total_carbohydrates,total_fat,total_fiber,total_sugar,total_sodium\n"+
+			"1,2024-05-10T20:00:00Z,dinner,1,Pasta,160,80,193,7.5,0,0,0,0,0,386,15,0,0,0,0,0\n"+
+			"2,2024-05-11T08:00:00Z,breakfast,4,\"Oatmeal, rolled\",50,100,380,13,0,0,0,0,0,190,6.5,0,0,0,0,0\n",
+		w.Body.String(),
+	)
+}
+
+func (suite *TestSuite) TestGetUserFoodsExportEscapesFormulas() {
+	token := suite.expectAuthentication()
+
+	from := time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC)
+	suite.mock.ExpectQuery("^SELECT food_items.id, .* FROM `food_items` JOIN foods").
+		WithArgs(float64(1), from, from.AddDate(0, 0, 1)).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "food_id", "name", "calories", "portion", "quantity", "timestamp", "meal"}).
+				AddRow(1, 1, 1, "=HYPERLINK(\"http://evil.test\")", 100, 100, 100, from.Add(8*time.Hour), models.MealBreakfast).
+				AddRow(2, 1, 2, "@SUM(A1)", 100, 100, 100, from.Add(9*time.Hour), models.MealBreakfast).
+				AddRow(3, 1, 3, "-Pasta", 100, 100, 100, from.Add(20*time.Hour), models.MealDinner),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food/export?from=2024-05-10&to=2024-05-10", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), records, 4)
+	assert.Equal(suite.T(), "'=HYPERLINK(\"http://evil.test\")", records[1][4])
+	assert.Equal(suite.T(), "'@SUM(A1)", records[2][4])
+	assert.Equal(suite.T(), "'-Pasta", records[3][4])
+	assert.Equal(suite.T(), "dinner", records[3][2])
+}
+
+func (suite *TestSuite) TestGetUserFoodsExportAsJSON() {
+	token := suite.expectAuthentication()
+
+	from := time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC)
+	suite.expectExportQuery(from, from.AddDate(0, 0, 2))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food/export?from=2024-05-10&to=2024-05-11&format=json", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody []schemas.JoinedFoodItem
+	err := json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Len(suite.T(), responseBody, 2)
+	assert.Equal(suite.T(), "Pasta", responseBody[0].Name)
+	assert.InDelta(suite.T(), 386.0, responseBody[0].Totals.Calories, 0.001)
+	assert.Equal(suite.T(), "Oatmeal, rolled", responseBody[1].Name)
+	assert.InDelta(suite.T(), 190.0, responseBody[1].Totals.Calories, 0.001)
+}
+
+func (suite *TestSuite) TestGetUserFoodsExportWithUnknownFormat() {
+	token := suite.expectAuthentication()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/food/export?format=pdf", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The format query string must be one of csv, json", responseBody.Message)
+}
+
+const diaryExport = "date,food,amount,calories,meal\n" +
+	"2024-05-10 08:00,Oatmeal,50,190,\n" +
+	"2024-05-10 20:00,pasta,160,386,\n" +
+	"2024-05-11 20:00,Grandma's Lasagna,300,450,\n"
+
+func (suite *TestSuite) expectImportFoodsQuery() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE name IN \\(\\?,\\?,\\?\\) AND foods.user_id IN \\(\\?,\\?\\)").
+		WithArgs("Oatmeal", "pasta", "Grandma's Lasagna", 0, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(1, 0, "Pasta", 193, 80).
+				AddRow(4, 0, "Oatmeal", 380, 100),
+		)
+}
+
+func (suite *TestSuite) TestPostUserFoodImportDryRun() {
+	token := suite.expectAuthentication()
+
+	suite.expectImportFoodsQuery()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/food/import?dry_run=true", strings.NewReader(diaryExport))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "text/csv")
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.DiaryImportReport
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), schemas.DiaryImportReport{
+		DryRun:  true,
+		Entries: 3,
+		Matched: []schemas.DiaryImportFood{
+			{ID: 4, Name: "Oatmeal", Calories: 380, Portion: 100, Entries: 1},
+			{ID: 1, Name: "Pasta", Calories: 193, Portion: 80, Entries: 1},
+		},
+		// 450 kcal for 300 g
+		NewFoods: []schemas.DiaryImportFood{
+			{Name: "Grandma's Lasagna", Calories: 150, Portion: 100, Entries: 1},
+		},
+		Errors: []schemas.ImportError{},
+	}, responseBody)
+}
+
+func (suite *TestSuite) TestPostUserFoodImportSuccessful() {
+	token := suite.expectAuthentication()
+
+	suite.expectImportFoodsQuery()
+
+	day := time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^INSERT INTO `foods`").
+		WithArgs(1, "Grandma's Lasagna", 150, 100, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	suite.mock.ExpectExec("^INSERT INTO `food_items` \\(`user_id`,`food_id`,`quantity`,`timestamp`,`meal`\\) VALUES \\(.+\\),\\(.+\\),\\(.+\\)").
+		WithArgs(
+			1, 4, 50, day.Add(8*time.Hour), models.MealBreakfast,
+			1, 1, 160, day.Add(20*time.Hour), models.MealDinner,
+			1, 7, 300, day.AddDate(0, 0, 1).Add(20*time.Hour), models.MealDinner,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 3))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/food/import", strings.NewReader(diaryExport))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "text/csv")
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.DiaryImportReport
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 201, w.Code)
+	assert.False(suite.T(), responseBody.DryRun)
+	assert.Equal(suite.T(), 3, responseBody.Entries)
+	assert.Equal(suite.T(), []schemas.DiaryImportFood{
+		{ID: 7, Name: "Grandma's Lasagna", Calories: 150, Portion: 100, Entries: 1},
+	}, responseBody.NewFoods)
+}
+
+func (suite *TestSuite) TestPostUserFoodImportWithInvalidRows() {
+	token := suite.expectAuthentication()
+
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `custom_meals` WHERE user_id = \\? AND name = \\?").
+		WithArgs(1, "brunch").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE name IN \\(\\?\\) AND foods.user_id IN \\(\\?,\\?\\)").
+		WithArgs("Pasta", 0, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(1, 0, "Pasta", 193, 80),
+		)
+
+	dataset := "date,food,amount,calories,meal\n" +
+		"2024-05-10 20:00,Pasta,160,386,Dinner\n" +
+		"2024-05-11 11:00,Pancakes,200,450,brunch\n" +
+		"yesterday,Pasta,160,386,\n"
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/food/import", strings.NewReader(dataset))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "text/csv")
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.DiaryImportReport
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), []schemas.ImportError{
+		{Row: 2, Name: "Pancakes", Error: "the meal brunch does not exist"},
+		{Row: 3, Error: `the date "yesterday" is formatted badly`},
+	}, responseBody.Errors)
+}
+
+func (suite *TestSuite) TestPostUserFoodImportTooLarge() {
+	token := suite.expectAuthentication()
+
+	dataset := diaryExport + strings.Repeat("2024-05-10 20:00,Pasta,160,386,Dinner\n", 150000)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/food/import", strings.NewReader(dataset))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "text/csv")
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 413, w.Code)
+	assert.Equal(suite.T(), "The export must be at most 5 MB", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostUserFoodImportTooLargeAsFile() {
+	token := suite.expectAuthentication()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", "diary.csv")
+	file.Write([]byte(diaryExport + strings.Repeat("2024-05-10 20:00,Pasta,160,386,Dinner\n", 150000)))
+	form.Close()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/food/import", &body)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 413, w.Code)
 }
 
 func TestRunSuite(t *testing.T) {
@@ -0,0 +1,304 @@
+package fooditemservice
+
+import (
+	mealservice "diet-app-backend/api/services/meal_service"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/authentication"
+	"diet-app-backend/util/diaryimport"
+	"diet-app-backend/util/search"
+	"diet-app-backend/util/tokens"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// importBatchSize bounds the number of diary entries inserted per statement.
+const importBatchSize = 500
+
+// maxImportSize bounds the size of the export, which is read entirely in
+// memory before anything is matched.
+const maxImportSize = 5 << 20
+
+// PostUserFoodImport imports the CSV export of another tracker into the diary
+// of the user, sent as the body of the request or as the file field of a
+// multipart form. Rows are matched to the foods the user can see by name,
+// and a custom food is created for each name left, its calories derived
+// from the first row that names it. With the dry_run query string set to
+// true, the report is returned without saving anything.
+func PostUserFoodImport(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	userId := uint(claims["id"].(float64))
+	dryRun := c.Query("dry_run") == "true"
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	body := io.Reader(c.Request.Body)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+
+		if isTooLarge(err) {
+			respondTooLarge(c)
+			return
+		}
+
+		if err != nil {
+			apierrors.Respond(c, http.StatusBadRequest, "The export must be sent in the file field")
+			return
+		}
+
+		file, err := fileHeader.Open()
+
+		if err != nil {
+			c.Error(err)
+			apierrors.Respond(c, http.StatusBadRequest, "The export could not be read")
+			return
+		}
+		defer file.Close()
+
+		body = file
+	}
+
+	entries, err := diaryimport.Read(body, authentication.GetUser(c).Location())
+
+	if isTooLarge(err) {
+		respondTooLarge(c)
+		return
+	}
+
+	if err != nil {
+		apierrors.Respond(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := schemas.DiaryImportReport{
+		DryRun:   dryRun,
+		Matched:  []schemas.DiaryImportFood{},
+		NewFoods: []schemas.DiaryImportFood{},
+		Errors:   []schemas.ImportError{},
+	}
+
+	if err := checkImportMeals(userId, entries); err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the meals")
+		return
+	}
+
+	foods, newFoods, err := matchImportFoods(userId, entries)
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the foods")
+		return
+	}
+
+	counts := map[string]int{}
+
+	for _, entry := range entries {
+		if entry.Err != nil {
+			report.Errors = append(report.Errors, schemas.ImportError{
+				Row:   entry.Row,
+				Name:  entry.Name,
+				Error: entry.Err.Error(),
+			})
+			continue
+		}
+
+		report.Entries++
+		counts[strings.ToLower(entry.Name)]++
+	}
+
+	if len(report.Errors) > 0 {
+		c.IndentedJSON(http.StatusBadRequest, struct {
+			apierrors.Error
+			schemas.DiaryImportReport
+		}{apierrors.New(http.StatusBadRequest, "Some rows of the export are invalid"), report})
+		return
+	}
+
+	if !dryRun && report.Entries > 0 {
+		if err := saveImport(userId, entries, foods, newFoods); err != nil {
+			apierrors.Internal(c, err, "The diary could not be imported")
+			return
+		}
+	}
+
+	for key, food := range foods {
+		report.Matched = append(report.Matched, importFood(*food, counts[key]))
+	}
+
+	slices.SortFunc(report.Matched, func(a, b schemas.DiaryImportFood) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, food := range newFoods {
+		report.NewFoods = append(report.NewFoods, importFood(*food, counts[strings.ToLower(food.Name)]))
+	}
+
+	if dryRun {
+		c.IndentedJSON(http.StatusOK, report)
+	} else {
+		c.IndentedJSON(http.StatusCreated, report)
+	}
+}
+
+// checkImportMeals marks the entries logged in a meal the user doesn't have
+// as invalid.
+func isTooLarge(err error) bool {
+	var maxBytesError *http.MaxBytesError
+
+	return errors.As(err, &maxBytesError)
+}
+
+func respondTooLarge(c *gin.Context) {
+	apierrors.Respond(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("The export must be at most %d MB", maxImportSize>>20))
+}
+
+func checkImportMeals(userId uint, entries []diaryimport.Entry) error {
+	exists := map[string]bool{}
+
+	for i, entry := range entries {
+		if entry.Err != nil {
+			continue
+		}
+
+		if _, ok := exists[entry.Meal]; !ok {
+			found, err := mealservice.MealExists(connection.Db, userId, entry.Meal)
+
+			if err != nil {
+				return err
+			}
+
+			exists[entry.Meal] = found
+		}
+
+		if !exists[entry.Meal] {
+			entries[i].Err = fmt.Errorf("the meal %s does not exist", entry.Meal)
+		}
+	}
+
+	return nil
+}
+
+// matchImportFoods returns the foods the entries name, keyed by lower case
+// name, along with the custom foods to create for the names that match no
+// food. A custom food of the user wins over a catalog food of the same name.
+func matchImportFoods(userId uint, entries []diaryimport.Entry) (map[string]*models.Food, []*models.Food, error) {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, entry := range entries {
+		key := strings.ToLower(entry.Name)
+
+		if entry.Err == nil && !seen[key] {
+			seen[key] = true
+			names = append(names, entry.Name)
+		}
+	}
+
+	foods := map[string]*models.Food{}
+	newFoods := []*models.Food{}
+
+	if len(names) == 0 {
+		return foods, newFoods, nil
+	}
+
+	var candidates []models.Food
+
+	if err := connection.Db.Scopes(models.VisibleFoods(userId)).Where("name IN ?", names).Find(&candidates).Error; err != nil {
+		return nil, nil, err
+	}
+
+	for i := range candidates {
+		key := strings.ToLower(candidates[i].Name)
+
+		if current, ok := foods[key]; !ok || current.UserID == models.CatalogOwner {
+			foods[key] = &candidates[i]
+		}
+	}
+
+	created := map[string]bool{}
+
+	for _, entry := range entries {
+		key := strings.ToLower(entry.Name)
+
+		if entry.Err != nil || foods[key] != nil || created[key] {
+			continue
+		}
+
+		// The new food is expressed per 100 g, like most nutrition labels
+		newFoods = append(newFoods, &models.Food{
+			UserID:   userId,
+			Name:     entry.Name,
+			Calories: int(math.Round(entry.Calories * 100 / float64(entry.Amount))),
+			Portion:  100,
+		})
+		created[key] = true
+	}
+
+	return foods, newFoods, nil
+}
+
+// saveImport creates the new foods and the diary entries in one transaction,
+// so that a failed import leaves the diary as it was.
+func saveImport(userId uint, entries []diaryimport.Entry, foods map[string]*models.Food, newFoods []*models.Food) error {
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		if len(newFoods) > 0 {
+			if err := tx.Create(newFoods).Error; err != nil {
+				return err
+			}
+		}
+
+		ids := map[string]uint{}
+
+		for key, food := range foods {
+			ids[key] = food.ID
+		}
+
+		for _, food := range newFoods {
+			ids[strings.ToLower(food.Name)] = food.ID
+		}
+
+		foodItems := []models.FoodItem{}
+
+		for _, entry := range entries {
+			foodItems = append(foodItems, models.FoodItem{
+				UserID:    userId,
+				FoodID:    ids[strings.ToLower(entry.Name)],
+				Quantity:  entry.Amount,
+				Timestamp: entry.Timestamp,
+				Meal:      entry.Meal,
+			})
+		}
+
+		return tx.CreateInBatches(foodItems, importBatchSize).Error
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, food := range newFoods {
+		search.Upsert(*food)
+	}
+
+	return nil
+}
+
+func importFood(food models.Food, entries int) schemas.DiaryImportFood {
+	return schemas.DiaryImportFood{
+		ID:       food.ID,
+		Name:     food.Name,
+		Calories: food.Calories,
+		Portion:  food.Portion,
+		Entries:  entries,
+	}
+}
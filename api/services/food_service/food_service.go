@@ -1,28 +1,175 @@
 package foodservice
 
 import (
+	recipeservice "diet-app-backend/api/services/recipe_service"
 	"diet-app-backend/database/connection"
 	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/barcodes"
+	"diet-app-backend/util/search"
+	"diet-app-backend/util/tokens"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// GetFoods returns a page of the foods matching the name query string. Once
+// the search index is loaded, matches tolerate typos and synonyms and are
+// ranked by relevance, otherwise names are matched with LIKE. Pages can also
+// be sorted by name, calories or calorie_density, and are chained through
+// the cursor of the previous page, see foodCursor.
 func GetFoods(c *gin.Context) {
 	name := c.DefaultQuery("name", "")
+	sort := c.DefaultQuery("sort", relevanceSort)
+	desc := c.DefaultQuery("order", "asc") == "desc"
+
+	userId := getUserId(c)
+
+	var matches []uint
+	indexed := false
+
+	if name != "" {
+		matches, indexed = search.Search(name, userId)
+	}
+
+	// Without a ranking, the most relevant order is the alphabetical one
+	if sort == relevanceSort && !indexed {
+		sort = "name"
+	}
+
+	if sort == relevanceSort {
+		desc = false
+	}
+
+	sortExpression, ok := foodSorts[sort]
+
+	if !ok && sort != relevanceSort {
+		apierrors.Respond(c, http.StatusBadRequest, "The sort query string must be one of relevance, name, calories, calorie_density")
+		return
+	}
+
+	limit, ok := parsePageSize(c.Query("limit"))
+
+	if !ok {
+		apierrors.Respond(c, http.StatusBadRequest, fmt.Sprintf("The limit query string must be between 1 and %d", maxPageSize))
+		return
+	}
+
+	var cursor *foodCursor
+
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		decoded, err := decodeFoodCursor(cursorStr)
+
+		if err != nil || decoded.Sort != sort || decoded.Desc != desc {
+			apierrors.Respond(c, http.StatusBadRequest, "The cursor does not belong to this sort order")
+			return
+		}
+
+		cursor = &decoded
+	}
+
+	filter := func(db *gorm.DB) *gorm.DB {
+		db = db.Scopes(models.VisibleFoods(userId))
+
+		if indexed {
+			return db.Where("foods.id IN ?", matches)
+		}
+
+		return db.Where("name LIKE ?", fmt.Sprintf("%%%s%%", name))
+	}
+
+	page := schemas.FoodPage{Items: []models.Food{}}
+
+	if indexed && len(matches) == 0 {
+		c.IndentedJSON(http.StatusOK, page)
+		return
+	}
+
+	if sort == relevanceSort {
+		getRankedFoods(c, matches, cursor, limit)
+		return
+	}
+
+	if err := connection.Db.Model(&models.Food{}).Scopes(filter).Count(&page.Total).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve foods")
+		return
+	}
+
+	query := connection.Db.Preload("Nutrients").Scopes(filter).Select(foodColumns)
+
+	if cursor != nil {
+		query = cursor.after(query)
+	}
+
+	direction := " ASC"
+
+	if desc {
+		direction = " DESC"
+	}
+
+	// One more food than requested tells whether there is a next page
+	err := query.Order(sortExpression + direction).Order("foods.id" + direction).Limit(limit + 1).Find(&page.Items).Error
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve foods")
+		return
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+
+		nextCursor := newFoodCursor(sort, desc, page.Items[limit-1]).encode()
+		page.NextCursor = &nextCursor
+	}
+
+	c.IndentedJSON(http.StatusOK, page)
+}
+
+// getRankedFoods responds with a page of search matches, which are already
+// ranked by the index and only need to be loaded.
+func getRankedFoods(c *gin.Context, matches []uint, cursor *foodCursor, limit int) {
+	offset := 0
+
+	if cursor != nil {
+		offset = min(cursor.Offset, len(matches))
+	}
+
+	ids := matches[offset:min(offset+limit, len(matches))]
+
+	page := schemas.FoodPage{Items: []models.Food{}, Total: int64(len(matches))}
 
 	var foods []models.Food
 
-	connection.Db.Select(
-		[]string{"id", "name", "calories", "portion"},
-	).Where(
-		"name LIKE ?", fmt.Sprintf("%%%s%%", name),
-	).Find(&foods)
+	if err := connection.Db.Preload("Nutrients").Scopes(models.VisibleFoods(getUserId(c))).
+		Select(foodColumns).Where("foods.id IN ?", ids).Find(&foods).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve foods")
+		return
+	}
+
+	foodsById := map[uint]models.Food{}
+
+	for _, food := range foods {
+		foodsById[food.ID] = food
+	}
+
+	for _, id := range ids {
+		// Foods deleted by another instance may linger in the index
+		if food, ok := foodsById[id]; ok {
+			page.Items = append(page.Items, food)
+		}
+	}
+
+	if offset+limit < len(matches) {
+		nextCursor := foodCursor{Sort: relevanceSort, Offset: offset + limit}.encode()
+		page.NextCursor = &nextCursor
+	}
 
-	c.IndentedJSON(http.StatusOK, foods)
+	c.IndentedJSON(http.StatusOK, page)
 }
 
 func GetFood(c *gin.Context) {
@@ -30,13 +177,273 @@ func GetFood(c *gin.Context) {
 
 	var food models.Food
 
-	err := connection.Db.First(&food, id).Error
+	err := connection.Db.Preload("Nutrients").Preload("Barcodes").Scopes(models.VisibleFoods(getUserId(c))).First(&food, id).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.IndentedJSON(http.StatusNotFound, gin.H{
-			"error": "Not Found",
-		})
+		apierrors.Respond(c, http.StatusNotFound, "Not Found")
 		return
 	}
 
 	c.IndentedJSON(http.StatusOK, food)
 }
+
+// GetFoodByBarcode returns the food a scanned barcode maps to. When the user
+// mapped the barcode to a custom food, it takes precedence over the catalog.
+func GetFoodByBarcode(c *gin.Context) {
+	code, err := barcodes.Normalize(c.Param("code"))
+
+	if err != nil {
+		apierrors.Respond(c, http.StatusBadRequest, "The barcode is not a valid EAN-13 or UPC-A code")
+		return
+	}
+
+	var food models.Food
+
+	err = connection.Db.Preload("Nutrients").Preload("Barcodes").Scopes(models.VisibleFoods(getUserId(c))).
+		Joins("JOIN food_barcodes ON food_barcodes.food_id = foods.id").
+		Where("food_barcodes.code = ?", code).
+		Order("foods.user_id DESC").
+		First(&food).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The normalized barcode lets the client prefill a custom food
+		c.IndentedJSON(http.StatusNotFound, struct {
+			apierrors.Error
+			Barcode string `json:"barcode"`
+		}{apierrors.New(http.StatusNotFound, "No food has this barcode"), code})
+		return
+	}
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the food")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, food)
+}
+
+func PostFood(c *gin.Context) {
+	createFood(c, models.CatalogOwner)
+}
+
+func PutFood(c *gin.Context) {
+	updateFood(c, models.CatalogOwner)
+}
+
+func DeleteFood(c *gin.Context) {
+	deleteFood(c, models.CatalogOwner)
+}
+
+func PostCustomFood(c *gin.Context) {
+	createFood(c, getUserId(c))
+}
+
+func PutCustomFood(c *gin.Context) {
+	updateFood(c, getUserId(c))
+}
+
+func DeleteCustomFood(c *gin.Context) {
+	deleteFood(c, getUserId(c))
+}
+
+// getUserId returns the id of the authenticated user, or CatalogOwner for
+// anonymous requests.
+func getUserId(c *gin.Context) uint {
+	claims, err := tokens.GetClaims(c)
+
+	if err != nil {
+		return models.CatalogOwner
+	}
+
+	id, ok := claims["id"].(float64)
+
+	if !ok {
+		return models.CatalogOwner
+	}
+
+	return uint(id)
+}
+
+func createFood(c *gin.Context, ownerId uint) {
+	var food models.Food
+
+	if !apierrors.BindJSON(c, &food) {
+		return
+	}
+
+	food.ID = 0
+	food.UserID = ownerId
+	food.Servings = 0
+	food.Ingredients = nil
+
+	if !normalizeBarcodes(c, &food) {
+		return
+	}
+
+	result := connection.Db.Create(&food)
+
+	if err := result.Error; err != nil {
+		handleSaveError(c, err, "A food could not be created")
+		return
+	}
+
+	search.Upsert(food)
+
+	c.IndentedJSON(http.StatusCreated, food)
+}
+
+func updateFood(c *gin.Context, ownerId uint) {
+	id := c.Param("id")
+
+	var updatedFood models.Food
+
+	if !apierrors.BindJSON(c, &updatedFood) {
+		return
+	}
+
+	var food models.Food
+
+	err := connection.Db.Where("user_id = ?", ownerId).First(&food, id).Error
+	if err != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	if food.IsRecipe() {
+		apierrors.Respond(c, http.StatusConflict, "Recipes can only be updated through their ingredients")
+		return
+	}
+
+	updatedFood.ID = food.ID
+	updatedFood.UserID = food.UserID
+	updatedFood.Servings = 0
+	updatedFood.Ingredients = nil
+
+	if !normalizeBarcodes(c, &updatedFood) {
+		return
+	}
+
+	// The nutrients and barcodes sent replace the existing ones instead of
+	// being merged with them, so that they can be removed from a food.
+	err = connection.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("food_id = ?", food.ID).Delete(&models.FoodNutrient{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("food_id = ?", food.ID).Delete(&models.FoodBarcode{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Save(&updatedFood).Error; err != nil {
+			return err
+		}
+
+		return recipeservice.RefreshRecipesUsing(tx, updatedFood.ID)
+	})
+
+	if err != nil {
+		handleSaveError(c, err, "Failed to update record")
+		return
+	}
+
+	search.Upsert(updatedFood)
+
+	c.IndentedJSON(http.StatusOK, updatedFood)
+}
+
+// normalizeBarcodes stores the barcodes of a food in their EAN-13 form under
+// the owner of the food, responding with an error and returning false when
+// one of them is invalid.
+func normalizeBarcodes(c *gin.Context, food *models.Food) bool {
+	for i := range food.Barcodes {
+		code, err := barcodes.Normalize(food.Barcodes[i].Code)
+
+		if err != nil {
+			apierrors.Respond(c, http.StatusBadRequest, fmt.Sprintf("The barcode %s is not a valid EAN-13 or UPC-A code", food.Barcodes[i].Code))
+			return false
+		}
+
+		food.Barcodes[i] = models.FoodBarcode{UserID: food.UserID, Code: code}
+	}
+
+	return true
+}
+
+func handleSaveError(c *gin.Context, err error, message string) {
+	if strings.Contains(err.Error(), "Duplicate entry") {
+		if strings.Contains(err.Error(), "idx_food_barcodes_user_id_code") {
+			apierrors.Respond(c, http.StatusConflict, "This barcode is already used by another food")
+			return
+		}
+
+		apierrors.Respond(c, http.StatusConflict, "This name is not available")
+		return
+	}
+
+	apierrors.Internal(c, err, message)
+}
+
+// deleteFood removes a food. Foods that are referenced by diary entries
+// can't be deleted, since that would rewrite the history of the users who
+// ate them. The same goes for ingredients of recipes.
+func deleteFood(c *gin.Context, ownerId uint) {
+	id := c.Param("id")
+
+	var food models.Food
+
+	err := connection.Db.Where("user_id = ?", ownerId).First(&food, id).Error
+	if err != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	// Recipes go through DeleteRecipe, which deletes their ingredients too
+	if food.IsRecipe() {
+		apierrors.Respond(c, http.StatusConflict, "Recipes can only be deleted through DELETE /user/recipe/:id")
+		return
+	}
+
+	var references int64
+
+	if err := connection.Db.Model(&models.FoodItem{}).Where("food_id = ?", food.ID).Count(&references).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to delete record")
+		return
+	}
+
+	if references > 0 {
+		apierrors.Respond(c, http.StatusConflict, "This food is used by diary entries and cannot be deleted")
+		return
+	}
+
+	var ingredientReferences int64
+
+	if err := connection.Db.Model(&models.RecipeIngredient{}).Where("food_id = ?", food.ID).Count(&ingredientReferences).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to delete record")
+		return
+	}
+
+	if ingredientReferences > 0 {
+		apierrors.Respond(c, http.StatusConflict, "This food is used by recipes and cannot be deleted")
+		return
+	}
+
+	err = connection.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("food_id = ?", food.ID).Delete(&models.FoodNutrient{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("food_id = ?", food.ID).Delete(&models.FoodBarcode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&food).Error
+	})
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to delete record")
+		return
+	}
+
+	search.Remove(food.ID)
+
+	c.IndentedJSON(http.StatusNoContent, nil)
+}
@@ -5,12 +5,17 @@ import (
 	"diet-app-backend/api/routes"
 	"diet-app-backend/database/connection"
 	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
 	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/search"
 	"diet-app-backend/util/tests"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -19,6 +24,22 @@ import (
 	"gorm.io/driver/mysql"
 )
 
+const email = "test.user@test.com"
+const firstName = "Joe"
+const lastName = "Doe"
+const password = "Str0ng-P@ssw0rd"
+
+const foodJson = `{
+	"name": "Pasta",
+	"calories": 193,
+	"portion": 80,
+	"protein": 5.6,
+	"carbohydrates": 38.4,
+	"nutrients": [{"name": "Iron", "amount": 1.3, "unit": "mg"}]
+}`
+
+var hashedPassword, _ = hashing.HashPassword(password)
+
 type TestSuite struct {
 	suite.Suite
 	db   *sql.DB
@@ -55,8 +76,12 @@ func (suite *TestSuite) TearDownTest() {
 }
 
 func (suite *TestSuite) TestGetFoodsSuccessful() {
-	suite.mock.ExpectQuery("^SELECT `id`,`name`,`calories`,`portion` FROM `foods` WHERE name LIKE \\?").
-		WithArgs(fmt.Sprintf("%%%s%%", "")).
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `foods` WHERE name LIKE \\? AND foods.user_id = \\?").
+		WithArgs(fmt.Sprintf("%%%s%%", ""), 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	suite.mock.ExpectQuery("^SELECT `id`,`user_id`,`name`,`calories`,`portion`,`protein`,`carbohydrates`,`fat`,`fiber`,`sugar`,`sodium`,`servings` FROM `foods` WHERE name LIKE \\? AND foods.user_id = \\? ORDER BY foods.name ASC,foods.id ASC LIMIT \\?").
+		WithArgs(fmt.Sprintf("%%%s%%", ""), 0, 21).
 		WillReturnRows(
 			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}).
 				AddRow(1, "Pasta", 193, 80).
@@ -64,6 +89,13 @@ func (suite *TestSuite) TestGetFoodsSuccessful() {
 				AddRow(3, "Tomato Sauce", 34, 100),
 		)
 
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients` WHERE `food_nutrients`.`food_id` IN \\(\\?,\\?,\\?\\)").
+		WithArgs(1, 2, 3).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}).
+				AddRow(1, 1, "Iron", 1.3, "mg"),
+		)
+
 	router := routes.SetupRouter()
 	w := httptest.NewRecorder()
 
@@ -71,37 +103,49 @@ func (suite *TestSuite) TestGetFoodsSuccessful() {
 
 	router.ServeHTTP(w, req)
 
-	var responseBody []models.Food
+	var responseBody schemas.FoodPage
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), int64(3), responseBody.Total)
+	assert.Nil(suite.T(), responseBody.NextCursor)
 
-	assert.Len(suite.T(), responseBody, 3)
+	assert.Len(suite.T(), responseBody.Items, 3)
 
-	assert.Equal(suite.T(), uint(1), responseBody[0].ID)
-	assert.Equal(suite.T(), "Pasta", responseBody[0].Name)
-	assert.Equal(suite.T(), 193, responseBody[0].Calories)
-	assert.Equal(suite.T(), 80, responseBody[0].Portion)
+	assert.Equal(suite.T(), uint(1), responseBody.Items[0].ID)
+	assert.Equal(suite.T(), "Pasta", responseBody.Items[0].Name)
+	assert.Equal(suite.T(), 193, responseBody.Items[0].Calories)
+	assert.Equal(suite.T(), 80, responseBody.Items[0].Portion)
 
-	assert.Equal(suite.T(), uint(2), responseBody[1].ID)
-	assert.Equal(suite.T(), "Grated Cheese", responseBody[1].Name)
-	assert.Equal(suite.T(), 492, responseBody[1].Calories)
-	assert.Equal(suite.T(), 100, responseBody[1].Portion)
+	assert.Equal(suite.T(), uint(2), responseBody.Items[1].ID)
+	assert.Equal(suite.T(), "Grated Cheese", responseBody.Items[1].Name)
+	assert.Equal(suite.T(), 492, responseBody.Items[1].Calories)
+	assert.Equal(suite.T(), 100, responseBody.Items[1].Portion)
 
-	assert.Equal(suite.T(), uint(3), responseBody[2].ID)
-	assert.Equal(suite.T(), "Tomato Sauce", responseBody[2].Name)
-	assert.Equal(suite.T(), 34, responseBody[2].Calories)
-	assert.Equal(suite.T(), 100, responseBody[2].Portion)
+	assert.Equal(suite.T(), uint(3), responseBody.Items[2].ID)
+	assert.Equal(suite.T(), "Tomato Sauce", responseBody.Items[2].Name)
+	assert.Equal(suite.T(), 34, responseBody.Items[2].Calories)
+	assert.Equal(suite.T(), 100, responseBody.Items[2].Portion)
 }
 
 func (suite *TestSuite) TestGetFoodsSuccessfulWithQueryString() {
-	suite.mock.ExpectQuery("^SELECT `id`,`name`,`calories`,`portion` FROM `foods` WHERE name LIKE \\?").
-		WithArgs(fmt.Sprintf("%%%s%%", "Pas")).
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `foods` WHERE name LIKE \\? AND foods.user_id = \\?").
+		WithArgs(fmt.Sprintf("%%%s%%", "Pas"), 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	suite.mock.ExpectQuery("^SELECT `id`,`user_id`,`name`,`calories`,`portion`,`protein`,`carbohydrates`,`fat`,`fiber`,`sugar`,`sodium`,`servings` FROM `foods` WHERE name LIKE \\? AND foods.user_id = \\? ORDER BY foods.name ASC,foods.id ASC LIMIT \\?").
+		WithArgs(fmt.Sprintf("%%%s%%", "Pas"), 0, 21).
 		WillReturnRows(
 			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}).
 				AddRow(1, "Pasta", 193, 80),
 		)
 
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients` WHERE `food_nutrients`.`food_id` = \\?").
+		WithArgs(1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}),
+		)
+
 	router := routes.SetupRouter()
 	w := httptest.NewRecorder()
 
@@ -109,25 +153,268 @@ func (suite *TestSuite) TestGetFoodsSuccessfulWithQueryString() {
 
 	router.ServeHTTP(w, req)
 
-	var responseBody []models.Food
+	var responseBody schemas.FoodPage
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), int64(1), responseBody.Total)
+	assert.Nil(suite.T(), responseBody.NextCursor)
 
-	assert.Len(suite.T(), responseBody, 1)
+	assert.Len(suite.T(), responseBody.Items, 1)
 
-	assert.Equal(suite.T(), uint(1), responseBody[0].ID)
-	assert.Equal(suite.T(), "Pasta", responseBody[0].Name)
-	assert.Equal(suite.T(), 193, responseBody[0].Calories)
-	assert.Equal(suite.T(), 80, responseBody[0].Portion)
+	assert.Equal(suite.T(), uint(1), responseBody.Items[0].ID)
+	assert.Equal(suite.T(), "Pasta", responseBody.Items[0].Name)
+	assert.Equal(suite.T(), 193, responseBody.Items[0].Calories)
+	assert.Equal(suite.T(), 80, responseBody.Items[0].Portion)
 }
 
-func (suite *TestSuite) TestGetFoodSuccessful() {
-	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
-		WithArgs("1", 1).
+func (suite *TestSuite) TestGetFoodsPaginatedByCalorieDensity() {
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `foods`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	suite.mock.ExpectQuery("^SELECT .* FROM `foods` WHERE name LIKE \\? AND foods.user_id = \\? "+
+		"ORDER BY foods.calories \\* 1.0e0 / foods.portion DESC,foods.id DESC LIMIT \\?").
+		WithArgs("%%", 0, 3).
 		WillReturnRows(
 			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}).
-				AddRow(1, "Pasta", 193, 80),
+				AddRow(2, "Grated Cheese", 492, 100).
+				AddRow(1, "Pasta", 193, 80).
+				AddRow(3, "Tomato Sauce", 34, 100),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food?sort=calorie_density&order=desc&limit=2", nil)
+
+	router.ServeHTTP(w, req)
+
+	var firstPage schemas.FoodPage
+	json.Unmarshal(w.Body.Bytes(), &firstPage)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), int64(3), firstPage.Total)
+	assert.Len(suite.T(), firstPage.Items, 2)
+	assert.Equal(suite.T(), "Pasta", firstPage.Items[1].Name)
+	assert.NotNil(suite.T(), firstPage.NextCursor)
+
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `foods`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	// The next page starts after Pasta, 193 kcal for 80 g
+	suite.mock.ExpectQuery("^SELECT .* FROM `foods` WHERE "+
+		"\\(foods.calories \\* \\? < \\? \\* foods.portion OR \\(foods.calories \\* \\? = \\? \\* foods.portion AND foods.id < \\?\\)\\) "+
+		"AND name LIKE \\? AND foods.user_id = \\? ORDER BY foods.calories \\* 1.0e0 / foods.portion DESC,foods.id DESC LIMIT \\?").
+		WithArgs(80, 193, 80, 193, 1, "%%", 0, 3).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}).
+				AddRow(3, "Tomato Sauce", 34, 100),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}))
+
+	w = httptest.NewRecorder()
+
+	req, _ = http.NewRequest("GET", "/food?sort=calorie_density&order=desc&limit=2&cursor="+*firstPage.NextCursor, nil)
+
+	router.ServeHTTP(w, req)
+
+	var secondPage schemas.FoodPage
+	json.Unmarshal(w.Body.Bytes(), &secondPage)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Len(suite.T(), secondPage.Items, 1)
+	assert.Equal(suite.T(), "Tomato Sauce", secondPage.Items[0].Name)
+	assert.Nil(suite.T(), secondPage.NextCursor)
+}
+
+func (suite *TestSuite) TestGetFoodsWithDatabaseError() {
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `foods`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	suite.mock.ExpectQuery("^SELECT `id`,.* FROM `foods`").
+		WillReturnError(errors.New("connection refused"))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "Failed to retrieve foods", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetFoodsWithUnknownSort() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food?sort=protein", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The sort query string must be one of relevance, name, calories, calorie_density", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetFoodsWithInvalidCursor() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food?cursor=not-a-cursor", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The cursor does not belong to this sort order", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetFoodsWithTooLargeLimit() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food?limit=1000", nil)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestGetFoodsRankedBySearchIndex() {
+	suite.mock.ExpectQuery("^SELECT `id`,`user_id`,`name` FROM `foods`").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name"}).
+				AddRow(1, 0, "Broccoli").
+				AddRow(2, 0, "Breast, chicken, roasted").
+				AddRow(3, 0, "Chicken breast").
+				AddRow(4, 2, "Chicken breast of another user").
+				AddRow(5, 0, "Chicken wings"),
+		)
+
+	search.Load()
+	defer search.Unload()
+
+	suite.mock.ExpectQuery("^SELECT `id`,`user_id`,`name`,`calories`,`portion`,`protein`,`carbohydrates`,`fat`,`fiber`,`sugar`,`sodium`,`servings` "+
+		"FROM `foods` WHERE foods.id IN \\(\\?,\\?\\) AND foods.user_id = \\?").
+		WithArgs(3, 2, 0).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(2, 0, "Breast, chicken, roasted", 165, 100).
+				AddRow(3, 0, "Chicken breast", 120, 100),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	// A typo and the words in another order than in the names
+	req, _ := http.NewRequest("GET", "/food?name=brest%20chicken", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.FoodPage
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), int64(2), responseBody.Total)
+	assert.Nil(suite.T(), responseBody.NextCursor)
+	assert.Len(suite.T(), responseBody.Items, 2)
+	// The name without any other word ranks first
+	assert.Equal(suite.T(), "Chicken breast", responseBody.Items[0].Name)
+	assert.Equal(suite.T(), "Breast, chicken, roasted", responseBody.Items[1].Name)
+}
+
+func (suite *TestSuite) TestGetFoodsCountsEverySearchMatch() {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "name"})
+
+	for i := 1; i <= 1500; i++ {
+		rows.AddRow(i, 0, fmt.Sprintf("Rice %d", i))
+	}
+
+	suite.mock.ExpectQuery("^SELECT `id`,`user_id`,`name` FROM `foods`").WillReturnRows(rows)
+
+	search.Load()
+	defer search.Unload()
+
+	suite.mock.ExpectQuery("^SELECT `id`,.* FROM `foods` WHERE foods.id IN \\(\\?\\) AND foods.user_id = \\?").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(1, 0, "Rice 1", 130, 100),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food?name=rice&limit=1", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.FoodPage
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), int64(1500), responseBody.Total)
+	assert.NotNil(suite.T(), responseBody.NextCursor)
+}
+
+func (suite *TestSuite) TestGetFoodsWithoutSearchMatches() {
+	suite.mock.ExpectQuery("^SELECT `id`,`user_id`,`name` FROM `foods`").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name"}).
+				AddRow(1, 0, "Broccoli"),
+		)
+
+	search.Load()
+	defer search.Unload()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food?name=salmon", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.FoodPage
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), int64(0), responseBody.Total)
+	assert.Empty(suite.T(), responseBody.Items)
+}
+
+func (suite *TestSuite) TestGetFoodSuccessful() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE `foods`.`id` = \\? AND foods.user_id = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs("1", 0, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "calories", "portion", "protein", "carbohydrates", "fat", "fiber", "sugar", "sodium"}).
+				AddRow(1, "Pasta", 193, 80, 5.6, 38.4, 0.8, 2.4, 0.8, 4),
+		)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_barcodes` WHERE `food_barcodes`.`food_id` = \\?").
+		WithArgs(1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "food_id", "user_id", "code"}).
+				AddRow(1, 1, 0, "8076800195057"),
+		)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients` WHERE `food_nutrients`.`food_id` = \\?").
+		WithArgs(1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}).
+				AddRow(1, 1, "Iron", 1.3, "mg").
+				AddRow(2, 1, "Vitamin B1", 0.1, "mg"),
 		)
 
 	router := routes.SetupRouter()
@@ -146,11 +433,22 @@ func (suite *TestSuite) TestGetFoodSuccessful() {
 	assert.Equal(suite.T(), "Pasta", responseBody.Name)
 	assert.Equal(suite.T(), 193, responseBody.Calories)
 	assert.Equal(suite.T(), 80, responseBody.Portion)
+	assert.Equal(suite.T(), 5.6, responseBody.Protein)
+	assert.Equal(suite.T(), 38.4, responseBody.Carbohydrates)
+	assert.Equal(suite.T(), 0.8, responseBody.Fat)
+	assert.Equal(suite.T(), 2.4, responseBody.Fiber)
+	assert.Equal(suite.T(), 0.8, responseBody.Sugar)
+	assert.Equal(suite.T(), 4.0, responseBody.Sodium)
+
+	assert.Len(suite.T(), responseBody.Nutrients, 2)
+	assert.Equal(suite.T(), "Iron", responseBody.Nutrients[0].Name)
+	assert.Equal(suite.T(), 1.3, responseBody.Nutrients[0].Amount)
+	assert.Equal(suite.T(), "mg", responseBody.Nutrients[0].Unit)
 }
 
 func (suite *TestSuite) TestGetFoodNotFound() {
-	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
-		WithArgs("1", 1).
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE `foods`.`id` = \\? AND foods.user_id = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs("1", 0, 1).
 		WillReturnRows(
 			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}),
 		)
@@ -166,7 +464,715 @@ func (suite *TestSuite) TestGetFoodNotFound() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 404, w.Code)
-	assert.Equal(suite.T(), "Not Found", responseBody.Error)
+	assert.Equal(suite.T(), "Not Found", responseBody.Message)
+}
+
+// getToken logs in a user with the given role and registers the queries
+// run by authentication.Authenticate.
+func (suite *TestSuite) getToken(role string) string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "role"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, role),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "role"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, role),
+		)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) TestPostFoodSuccessful() {
+	token := suite.getToken(models.RoleAdmin)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `foods`").
+		WithArgs(0, "Pasta", 193, 80, 5.6, 38.4, 0.0, 0.0, 0.0, 0.0, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectExec("INSERT INTO `food_nutrients`").
+		WithArgs(1, "Iron", 1.3, "mg").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/food", strings.NewReader(foodJson))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.Food
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 201, w.Code)
+	assert.Equal(suite.T(), uint(1), responseBody.ID)
+	assert.Equal(suite.T(), "Pasta", responseBody.Name)
+	assert.Equal(suite.T(), 193, responseBody.Calories)
+	assert.Equal(suite.T(), 80, responseBody.Portion)
+	assert.Len(suite.T(), responseBody.Nutrients, 1)
+}
+
+func (suite *TestSuite) TestPostFoodRequiresAdmin() {
+	token := suite.getToken(models.RoleUser)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/food", strings.NewReader(foodJson))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Permission denied", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostFoodAllowedForDietitian() {
+	token := suite.getToken(models.RoleDietitian)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `foods`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectExec("INSERT INTO `food_nutrients`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/food", strings.NewReader(foodJson))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 201, w.Code)
+}
+
+func (suite *TestSuite) TestPostFoodWithoutAuthorization() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/food", strings.NewReader(foodJson))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostFoodRequiresName() {
+	token := suite.getToken(models.RoleAdmin)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/food", strings.NewReader(`{"calories": 193, "portion": 80}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestPutFoodSuccessful() {
+	token := suite.getToken(models.RoleAdmin)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE user_id = \\? AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(0, "1", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}).
+				AddRow(1, "Pasta", 150, 80),
+		)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^DELETE FROM `food_nutrients` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^DELETE FROM `food_barcodes` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectExec("^UPDATE `foods`").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO `food_nutrients`").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE id IN \\(SELECT `recipe_id` FROM `recipe_ingredients` WHERE food_id = \\?\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion", "servings"}))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/food/1", strings.NewReader(foodJson))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.Food
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), uint(1), responseBody.ID)
+	assert.Equal(suite.T(), 193, responseBody.Calories)
+}
+
+func (suite *TestSuite) TestPutFoodRefreshesRecipes() {
+	token := suite.getToken(models.RoleAdmin)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE user_id = \\? AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(0, "1", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}).
+				AddRow(1, "Pasta", 150, 80),
+		)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^DELETE FROM `food_nutrients` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^DELETE FROM `food_barcodes` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectExec("^UPDATE `foods`").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE id IN \\(SELECT `recipe_id` FROM `recipe_ingredients` WHERE food_id = \\?\\)").
+		WithArgs(1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion", "servings"}).
+				AddRow(5, 1, "Pasta salad", 201, 100, 2),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `recipe_ingredients` WHERE `recipe_ingredients`.`recipe_id` = \\?").
+		WithArgs(5).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "recipe_id", "food_id", "quantity"}).
+				AddRow(1, 5, 1, 200),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}).
+				AddRow(1, "Pasta", 193, 80),
+		)
+	suite.mock.ExpectExec("^UPDATE `foods` SET `calories`=\\?,`portion`=\\?").
+		WithArgs(241, 100, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/food/1", strings.NewReader(`{"name": "Pasta", "calories": 193, "portion": 80}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 200, w.Code)
+}
+
+func (suite *TestSuite) TestPutFoodNotFound() {
+	token := suite.getToken(models.RoleAdmin)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE user_id = \\? AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(0, "1", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/food/1", strings.NewReader(foodJson))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 404, w.Code)
+	assert.Equal(suite.T(), "Not Found", responseBody.Message)
+}
+
+func (suite *TestSuite) TestDeleteFoodSuccessful() {
+	token := suite.getToken(models.RoleAdmin)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE user_id = \\? AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(0, "1", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}).
+				AddRow(1, "Pasta", 193, 80),
+		)
+
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `food_items` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `recipe_ingredients` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^DELETE FROM `food_nutrients` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^DELETE FROM `food_barcodes` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectExec("^DELETE FROM `foods` WHERE `foods`.`id` = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/food/1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 204, w.Code)
+}
+
+func (suite *TestSuite) TestDeleteFoodRequiresAdmin() {
+	token := suite.getToken(models.RoleDietitian)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/food/1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Permission denied", responseBody.Message)
+}
+
+func (suite *TestSuite) TestDeleteFoodUsedByDiaryEntries() {
+	token := suite.getToken(models.RoleAdmin)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE user_id = \\? AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(0, "1", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "calories", "portion"}).
+				AddRow(1, "Pasta", 193, 80),
+		)
+
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `food_items` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/food/1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 409, w.Code)
+	assert.Equal(suite.T(), "This food is used by diary entries and cannot be deleted", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetFoodsIncludesCustomFoods() {
+	token := suite.getToken(models.RoleUser)
+
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `foods` WHERE name LIKE \\? AND foods.user_id IN \\(\\?,\\?\\)").
+		WithArgs(fmt.Sprintf("%%%s%%", "lasagna"), 0, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	suite.mock.ExpectQuery("^SELECT `id`,`user_id`,`name`,`calories`,`portion`,`protein`,`carbohydrates`,`fat`,`fiber`,`sugar`,`sodium`,`servings` FROM `foods` WHERE name LIKE \\? AND foods.user_id IN \\(\\?,\\?\\) ORDER BY foods.name ASC,foods.id ASC LIMIT \\?").
+		WithArgs(fmt.Sprintf("%%%s%%", "lasagna"), 0, 1, 21).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(1, 0, "Lasagna", 135, 100).
+				AddRow(2, 1, "Grandma's lasagna", 160, 100),
+		)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients` WHERE `food_nutrients`.`food_id` IN \\(\\?,\\?\\)").
+		WithArgs(1, 2).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food?name=lasagna", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.FoodPage
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), int64(2), responseBody.Total)
+	assert.Nil(suite.T(), responseBody.NextCursor)
+	assert.Len(suite.T(), responseBody.Items, 2)
+	assert.Equal(suite.T(), uint(0), responseBody.Items[0].UserID)
+	assert.Equal(suite.T(), uint(1), responseBody.Items[1].UserID)
+}
+
+func (suite *TestSuite) TestPostCustomFoodSuccessful() {
+	token := suite.getToken(models.RoleUser)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `foods`").
+		WithArgs(1, "Pasta", 193, 80, 5.6, 38.4, 0.0, 0.0, 0.0, 0.0, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectExec("INSERT INTO `food_nutrients`").
+		WithArgs(1, "Iron", 1.3, "mg").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/custom-food", strings.NewReader(foodJson))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.Food
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 201, w.Code)
+	assert.Equal(suite.T(), uint(1), responseBody.ID)
+	assert.Equal(suite.T(), uint(1), responseBody.UserID)
+	assert.Equal(suite.T(), "Pasta", responseBody.Name)
+}
+
+func (suite *TestSuite) TestPostCustomFoodWithoutAuthorization() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/custom-food", strings.NewReader(foodJson))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 403, w.Code)
+}
+
+func (suite *TestSuite) TestDeleteCustomFoodOfAnotherUser() {
+	token := suite.getToken(models.RoleUser)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE user_id = \\? AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(1, "2", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/custom-food/2", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 404, w.Code)
+	assert.Equal(suite.T(), "Not Found", responseBody.Message)
+}
+
+func (suite *TestSuite) TestDeleteCustomFoodThatIsARecipe() {
+	token := suite.getToken(models.RoleUser)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE user_id = \\? AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(1, "3", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion", "servings"}).
+				AddRow(3, 1, "Lasagna", 160, 350, 4),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/custom-food/3", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	// The ingredients of the recipe are left untouched
+	assert.Equal(suite.T(), 409, w.Code)
+	assert.Equal(suite.T(), "Recipes can only be deleted through DELETE /user/recipe/:id", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetFoodByBarcodeSuccessful() {
+	suite.mock.ExpectQuery("^SELECT `foods`.`id`,.* FROM `foods` JOIN food_barcodes ON food_barcodes.food_id = foods.id "+
+		"WHERE food_barcodes.code = \\? AND foods.user_id = \\? ORDER BY foods.user_id DESC,`foods`.`id` LIMIT \\?").
+		WithArgs("0036000291452", 0, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(7, 0, "Tomato Ketchup", 100, 100),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_barcodes` WHERE `food_barcodes`.`food_id` = \\?").
+		WithArgs(7).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "food_id", "user_id", "code"}).
+				AddRow(1, 7, 0, "0036000291452"),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `food_nutrients` WHERE `food_nutrients`.`food_id` = \\?").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "food_id", "name", "amount", "unit"}))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	// The UPC-A form of the barcode
+	req, _ := http.NewRequest("GET", "/food/barcode/036000291452", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.Food
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), uint(7), responseBody.ID)
+	assert.Equal(suite.T(), "Tomato Ketchup", responseBody.Name)
+	assert.Equal(suite.T(), "0036000291452", responseBody.Barcodes[0].Code)
+}
+
+func (suite *TestSuite) TestGetFoodByBarcodeNotFound() {
+	suite.mock.ExpectQuery("^SELECT `foods`.`id`,.* FROM `foods` JOIN food_barcodes").
+		WithArgs("5449000000996", 0, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food/barcode/5449000000996", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody struct {
+		tests.GenericErrorResponseBody
+		Barcode string
+	}
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 404, w.Code)
+	assert.Equal(suite.T(), "No food has this barcode", responseBody.Message)
+	assert.Equal(suite.T(), "5449000000996", responseBody.Barcode)
+}
+
+func (suite *TestSuite) TestGetFoodByBarcodeWithWrongCheckDigit() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/food/barcode/5449000000997", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The barcode is not a valid EAN-13 or UPC-A code", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostCustomFoodWithBarcodes() {
+	token := suite.getToken(models.RoleUser)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `foods`").
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	suite.mock.ExpectExec("INSERT INTO `food_barcodes`").
+		WithArgs(9, 1, "0036000291452", 9, 1, "5449000000996").
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/custom-food", strings.NewReader(
+		`{"name": "Ketchup", "calories": 100, "portion": 100, "barcodes": [{"code": "036000291452"}, {"code": "5449000000996"}]}`,
+	))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.Food
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 201, w.Code)
+	assert.Equal(suite.T(), "0036000291452", responseBody.Barcodes[0].Code)
+	assert.Equal(suite.T(), "5449000000996", responseBody.Barcodes[1].Code)
+}
+
+func (suite *TestSuite) TestPostCustomFoodWithInvalidBarcode() {
+	token := suite.getToken(models.RoleUser)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/custom-food", strings.NewReader(
+		`{"name": "Ketchup", "calories": 100, "portion": 100, "barcodes": [{"code": "123"}]}`,
+	))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The barcode 123 is not a valid EAN-13 or UPC-A code", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostCustomFoodWithBarcodeOfAnotherFood() {
+	token := suite.getToken(models.RoleUser)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `foods`").
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	suite.mock.ExpectExec("INSERT INTO `food_barcodes`").
+		WillReturnError(errors.New("Error 1062 (23000): Duplicate entry '1-5449000000996' for key 'food_barcodes.idx_food_barcodes_user_id_code'"))
+	suite.mock.ExpectRollback()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/custom-food", strings.NewReader(
+		`{"name": "Cola", "calories": 42, "portion": 100, "barcodes": [{"code": "5449000000996"}]}`,
+	))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 409, w.Code)
+	assert.Equal(suite.T(), "This barcode is already used by another food", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostFoodImportSuccessful() {
+	token := suite.getToken(models.RoleAdmin)
+
+	// Pasta is matched by barcode and updated
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("^SELECT `foods`.`id`,.* FROM `foods` JOIN food_barcodes ON food_barcodes.food_id = foods.id "+
+		"WHERE food_barcodes.user_id = \\? AND food_barcodes.code IN \\(\\?\\) ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(0, "8076800195057", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(1, 0, "Spaghetti", 150, 80),
+		)
+	suite.mock.ExpectExec("^DELETE FROM `food_nutrients` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectExec("^DELETE FROM `food_barcodes` WHERE food_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^UPDATE `foods`").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO `food_barcodes`").
+		WithArgs(1, 0, "8076800195057").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE id IN \\(SELECT `recipe_id` FROM `recipe_ingredients` WHERE food_id = \\?\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion", "servings"}))
+	suite.mock.ExpectCommit()
+
+	// Rice has no barcode and no food has its name yet
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE user_id = \\? AND name = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(0, "Rice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}))
+	suite.mock.ExpectExec("INSERT INTO `foods`").
+		WithArgs(0, "Rice", 130, 100, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 0).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	dataset := "name,calories,portion,barcodes\nPasta,193,80,8076800195057\nCheese,many,100,\nRice,130,100,\n"
+
+	req, _ := http.NewRequest("POST", "/food/import", strings.NewReader(dataset))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "text/csv")
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.ImportReport
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), 1, responseBody.Created)
+	assert.Equal(suite.T(), 1, responseBody.Updated)
+	assert.Equal(suite.T(), 1, responseBody.Failed)
+	assert.Equal(suite.T(), []schemas.ImportError{
+		{Row: 2, Name: "Cheese", Error: "calories is not a whole number"},
+	}, responseBody.Errors)
+}
+
+func (suite *TestSuite) TestPostFoodImportWithUnknownFormat() {
+	token := suite.getToken(models.RoleAdmin)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/food/import", strings.NewReader("name;calories;portion"))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "text/plain")
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "the dataset must be a CSV or JSON file", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostFoodImportRequiresAdmin() {
+	token := suite.getToken(models.RoleDietitian)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/food/import", strings.NewReader("[]"))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("Content-Type", "application/json")
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Permission denied", responseBody.Message)
 }
 
 func TestRunSuite(t *testing.T) {
@@ -0,0 +1,198 @@
+package foodservice
+
+import (
+	recipeservice "diet-app-backend/api/services/recipe_service"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/foodimport"
+	"diet-app-backend/util/search"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errImportRecipe = errors.New("a recipe has this name or barcode and cannot be overwritten")
+
+// PostFoodImport imports a CSV or JSON dataset into the catalog. The dataset
+// is either the body of the request, with a matching content type, or the
+// file field of a multipart form.
+func PostFoodImport(c *gin.Context) {
+	body := io.Reader(c.Request.Body)
+	format := foodimport.FormatOf(c.ContentType())
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+
+		if err != nil {
+			apierrors.Respond(c, http.StatusBadRequest, "The dataset must be sent in the file field")
+			return
+		}
+
+		file, err := fileHeader.Open()
+
+		if err != nil {
+			c.Error(err)
+			apierrors.Respond(c, http.StatusBadRequest, "The dataset could not be read")
+			return
+		}
+		defer file.Close()
+
+		body = file
+		format = foodimport.FormatOf(fileHeader.Filename)
+	}
+
+	reader, err := foodimport.NewReader(body, format)
+
+	if err != nil {
+		apierrors.Respond(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := ImportFoods(reader)
+
+	if err != nil {
+		// The rows before the malformed part were imported nonetheless
+		c.IndentedJSON(http.StatusBadRequest, struct {
+			apierrors.Error
+			Report schemas.ImportReport `json:"report"`
+		}{apierrors.New(http.StatusBadRequest, err.Error()), report})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, report)
+}
+
+// ImportFoods upserts every valid food of a dataset into the catalog, each in
+// its own transaction so that a bad row doesn't abort the import. Foods are
+// matched by barcode first, then by name. An error is returned only when the
+// dataset can't be read any further.
+func ImportFoods(reader foodimport.Reader) (schemas.ImportReport, error) {
+	report := schemas.ImportReport{Errors: []schemas.ImportError{}}
+
+	for {
+		record, err := reader.Next()
+
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+
+		if err != nil {
+			return report, err
+		}
+
+		if record.Err == nil {
+			var created bool
+
+			created, record.Err = upsertCatalogFood(&record.Food)
+
+			if record.Err == nil && created {
+				report.Created++
+			} else if record.Err == nil {
+				report.Updated++
+			}
+		}
+
+		if record.Err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, schemas.ImportError{
+				Row:   record.Row,
+				Name:  record.Food.Name,
+				Error: importErrorMessage(record.Err),
+			})
+		}
+	}
+}
+
+func upsertCatalogFood(food *models.Food) (created bool, err error) {
+	food.ID = 0
+	food.UserID = models.CatalogOwner
+	food.Servings = 0
+	food.Ingredients = nil
+
+	for i := range food.Barcodes {
+		food.Barcodes[i].UserID = models.CatalogOwner
+	}
+
+	err = connection.Db.Transaction(func(tx *gorm.DB) error {
+		existing, err := findCatalogFood(tx, *food)
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			created = true
+			return tx.Create(food).Error
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if existing.IsRecipe() {
+			return errImportRecipe
+		}
+
+		food.ID = existing.ID
+
+		if err := tx.Where("food_id = ?", food.ID).Delete(&models.FoodNutrient{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("food_id = ?", food.ID).Delete(&models.FoodBarcode{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Save(food).Error; err != nil {
+			return err
+		}
+
+		return recipeservice.RefreshRecipesUsing(tx, food.ID)
+	})
+
+	if err == nil {
+		search.Upsert(*food)
+	}
+
+	return created, err
+}
+
+// findCatalogFood looks for the catalog food an imported food stands for,
+// by barcode since names vary between datasets, or else by name.
+func findCatalogFood(tx *gorm.DB, food models.Food) (models.Food, error) {
+	var existing models.Food
+
+	if len(food.Barcodes) > 0 {
+		codes := []string{}
+
+		for _, barcode := range food.Barcodes {
+			codes = append(codes, barcode.Code)
+		}
+
+		err := tx.Joins("JOIN food_barcodes ON food_barcodes.food_id = foods.id").
+			Where("food_barcodes.user_id = ? AND food_barcodes.code IN ?", models.CatalogOwner, codes).
+			First(&existing).Error
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return existing, err
+		}
+	}
+
+	err := tx.Where("user_id = ? AND name = ?", models.CatalogOwner, food.Name).First(&existing).Error
+
+	return existing, err
+}
+
+func importErrorMessage(err error) string {
+	if strings.Contains(err.Error(), "Duplicate entry") {
+		if strings.Contains(err.Error(), "idx_food_barcodes_user_id_code") {
+			return "This barcode is already used by another food"
+		}
+
+		return "This name is not available"
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,126 @@
+package foodservice
+
+import (
+	"diet-app-backend/database/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+const relevanceSort = "relevance"
+
+var foodColumns = []string{"id", "user_id", "name", "calories", "portion", "protein", "carbohydrates", "fat", "fiber", "sugar", "sodium", "servings"}
+
+const defaultPageSize = 20
+const maxPageSize = 100
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// foodSorts maps the sort query string of GET /food to the expression foods
+// are ordered by. Calorie density is computed in floating point so that it
+// is not rounded like a MySQL decimal division would be.
+var foodSorts = map[string]string{
+	"name":            "foods.name",
+	"calories":        "foods.calories",
+	"calorie_density": "foods.calories * 1.0e0 / foods.portion",
+}
+
+// foodCursor is the position of the last food of a page. It holds the values
+// the page is sorted by, and the id to break ties, so that the next page
+// starts right after it even if foods were added or removed in the meantime.
+// Search results ranked by relevance are recomputed for each page, so they
+// are paginated by Offset instead.
+type foodCursor struct {
+	Sort     string `json:"s"`
+	Desc     bool   `json:"d"`
+	ID       uint   `json:"i,omitempty"`
+	Name     string `json:"n,omitempty"`
+	Calories int    `json:"c,omitempty"`
+	Portion  int    `json:"p,omitempty"`
+	Offset   int    `json:"o,omitempty"`
+}
+
+func newFoodCursor(sort string, desc bool, food models.Food) foodCursor {
+	return foodCursor{
+		Sort:     sort,
+		Desc:     desc,
+		ID:       food.ID,
+		Name:     food.Name,
+		Calories: food.Calories,
+		Portion:  food.Portion,
+	}
+}
+
+func (cursor foodCursor) encode() string {
+	data, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeFoodCursor(value string) (foodCursor, error) {
+	var cursor foodCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil {
+		return cursor, errInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, errInvalidCursor
+	}
+
+	if _, ok := foodSorts[cursor.Sort]; !ok && cursor.Sort != relevanceSort {
+		return cursor, errInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// after restricts a query to the foods that come after the cursor in its
+// sort order.
+func (cursor foodCursor) after(db *gorm.DB) *gorm.DB {
+	operator := ">"
+
+	if cursor.Desc {
+		operator = "<"
+	}
+
+	switch cursor.Sort {
+	case "calories":
+		return db.Where(
+			"foods.calories "+operator+" ? OR (foods.calories = ? AND foods.id "+operator+" ?)",
+			cursor.Calories, cursor.Calories, cursor.ID,
+		)
+	case "calorie_density":
+		// Cross-multiplying compares densities exactly, without dividing
+		return db.Where(
+			"foods.calories * ? "+operator+" ? * foods.portion OR (foods.calories * ? = ? * foods.portion AND foods.id "+operator+" ?)",
+			cursor.Portion, cursor.Calories, cursor.Portion, cursor.Calories, cursor.ID,
+		)
+	default:
+		return db.Where(
+			"foods.name "+operator+" ? OR (foods.name = ? AND foods.id "+operator+" ?)",
+			cursor.Name, cursor.Name, cursor.ID,
+		)
+	}
+}
+
+// parsePageSize reads the limit query string, falling back to the default
+// page size when it is missing.
+func parsePageSize(value string) (int, bool) {
+	if value == "" {
+		return defaultPageSize, true
+	}
+
+	limit, err := strconv.Atoi(value)
+
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0, false
+	}
+
+	return limit, true
+}
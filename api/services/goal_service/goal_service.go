@@ -0,0 +1,179 @@
+package goalservice
+
+import (
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/authentication"
+	"diet-app-backend/util/energy"
+	"diet-app-backend/util/numbers"
+	"diet-app-backend/util/tokens"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxRate bounds the weekly weight change goals are suggested for, in kg.
+const maxRate = 1.0
+
+// GoalBefore returns the calorie goal of the user that was in force right
+// before the given instant, or nil when the user had not set one yet.
+func GoalBefore(db *gorm.DB, userId any, instant time.Time) (*models.CalorieGoal, error) {
+	var goal models.CalorieGoal
+
+	err := db.Where("user_id = ? AND effective_from < ?", userId, instant).
+		Order("effective_from desc").
+		First(&goal).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &goal, nil
+}
+
+func GetCalorieGoal(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	goal, err := GoalBefore(connection.Db, claims["id"], time.Now())
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the calorie goal")
+		return
+	}
+
+	if goal == nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, goal)
+}
+
+func GetCalorieGoalHistory(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	goals := []models.CalorieGoal{}
+
+	if err := connection.Db.Where("user_id = ?", claims["id"]).Order("effective_from desc").Find(&goals).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the calorie goals")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, goals)
+}
+
+func PutCalorieGoal(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	var updateGoal schemas.UpdateCalorieGoal
+
+	if !apierrors.BindJSON(c, &updateGoal) {
+		return
+	}
+
+	goal := models.CalorieGoal{
+		UserID:        uint(claims["id"].(float64)),
+		Calories:      updateGoal.Calories,
+		EffectiveFrom: time.Now(),
+	}
+
+	if err := connection.Db.Create(&goal).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to save the calorie goal")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, goal)
+}
+
+// GetCalorieGoalSuggestion suggests a daily calorie target to change weight
+// by the rate query string, in kg per week, from the profile of the user and
+// their latest weight measurement. The target never goes below the minimum
+// safe intake.
+func GetCalorieGoalSuggestion(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	rate := 0.0
+
+	if rateStr, exists := c.GetQuery("rate"); exists {
+		var err error
+
+		if rate, err = numbers.ParseFinite(rateStr); err != nil || math.Abs(rate) > maxRate {
+			apierrors.Respond(c, http.StatusBadRequest, fmt.Sprintf("The rate query string must be a number of kg per week between -%g and %g", maxRate, maxRate))
+			return
+		}
+	}
+
+	user := authentication.GetUser(c)
+	age, hasAge := user.Age(time.Now().In(user.Location()))
+
+	missing := []string{}
+
+	if user.Height == 0 {
+		missing = append(missing, "height")
+	}
+
+	if !hasAge {
+		missing = append(missing, "birth_date")
+	}
+
+	if user.Sex == "" {
+		missing = append(missing, "sex")
+	}
+
+	if user.ActivityLevel == "" {
+		missing = append(missing, "activity_level")
+	}
+
+	if len(missing) > 0 {
+		apierrors.Respond(c, http.StatusBadRequest, fmt.Sprintf("The profile has no %s", strings.Join(missing, ", ")))
+		return
+	}
+
+	var weight models.Measurement
+
+	err := connection.Db.Where("user_id = ? AND kind = ?", claims["id"], models.MeasurementWeight).
+		Order("timestamp desc").
+		First(&weight).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apierrors.Respond(c, http.StatusBadRequest, "A weight measurement is needed to suggest a goal")
+		return
+	}
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the weight")
+		return
+	}
+
+	suggestion := schemas.GoalSuggestion{
+		Weight: models.ConvertMeasurement(weight.Value, weight.Unit, "kg"),
+		Age:    age,
+		Rate:   rate,
+	}
+
+	suggestion.BMR = energy.BMR(user.Sex, suggestion.Weight, user.Height, age)
+	suggestion.TDEE = energy.TDEE(suggestion.BMR, user.ActivityLevel)
+
+	target := energy.Target(suggestion.TDEE, rate)
+
+	if minimum := energy.MinimumCalories[user.Sex]; target < minimum {
+		target = minimum
+		suggestion.Limited = true
+	}
+
+	suggestion.Calories = uint(math.Round(target))
+
+	c.IndentedJSON(http.StatusOK, suggestion)
+}
@@ -0,0 +1,409 @@
+package goalservice_test
+
+import (
+	"database/sql"
+	"diet-app-backend/api/routes"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/tests"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+)
+
+const email = "test.user@test.com"
+const firstName = "Joe"
+const lastName = "Doe"
+const password = "Str0ng-P@ssw0rd"
+
+var hashedPassword, _ = hashing.HashPassword(password)
+
+type TestSuite struct {
+	suite.Suite
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+func (suite *TestSuite) SetupTest() {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		suite.T().Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	dialector := mysql.New(mysql.Config{
+		DSN:                       "sqlmock_db_0",
+		DriverName:                "mysql",
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	})
+	connection.Connect(dialector)
+
+	suite.db = db
+	suite.mock = mock
+
+	config.LoadEnv("../../../.")
+}
+
+func (suite *TestSuite) TearDownTest() {
+	suite.db.Close()
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TestSuite) getToken() string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) TestPutCalorieGoalSuccessful() {
+	token := suite.getToken()
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `calorie_goals`").
+		WithArgs(1, 2000, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/user/goal", strings.NewReader(`{"calories": 2000}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.CalorieGoal
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), uint(2000), responseBody.Calories)
+	assert.WithinDuration(suite.T(), time.Now(), responseBody.EffectiveFrom, time.Minute)
+}
+
+func (suite *TestSuite) TestPutCalorieGoalRequiresCalories() {
+	token := suite.getToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/user/goal", strings.NewReader(`{"calories": 0}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestPutCalorieGoalWithoutAuthorization() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/user/goal", strings.NewReader(`{"calories": 2000}`))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetCalorieGoalSuccessful() {
+	token := suite.getToken()
+
+	effectiveFrom := time.Date(2024, time.May, 10, 9, 30, 0, 0, time.UTC)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `calorie_goals` WHERE user_id = \\? AND effective_from < \\? ORDER BY effective_from desc,`calorie_goals`.`id` LIMIT \\?").
+		WithArgs(float64(1), sqlmock.AnyArg(), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "calories", "effective_from"}).
+				AddRow(2, 1, 1800, effectiveFrom),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/goal", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.CalorieGoal
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), uint(1800), responseBody.Calories)
+	assert.True(suite.T(), effectiveFrom.Equal(responseBody.EffectiveFrom))
+}
+
+func (suite *TestSuite) TestGetCalorieGoalNotFound() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `calorie_goals`").
+		WithArgs(float64(1), sqlmock.AnyArg(), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "calories", "effective_from"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/goal", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 404, w.Code)
+	assert.Equal(suite.T(), "Not found", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetCalorieGoalHistory() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `calorie_goals` WHERE user_id = \\? ORDER BY effective_from desc").
+		WithArgs(float64(1)).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "calories", "effective_from"}).
+				AddRow(2, 1, 1800, time.Date(2024, time.May, 10, 9, 30, 0, 0, time.UTC)).
+				AddRow(1, 1, 2200, time.Date(2024, time.January, 2, 8, 0, 0, 0, time.UTC)),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/goal/history", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody []models.CalorieGoal
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Len(suite.T(), responseBody, 2)
+	assert.Equal(suite.T(), uint(1800), responseBody[0].Calories)
+	assert.Equal(suite.T(), uint(2200), responseBody[1].Calories)
+}
+
+func (suite *TestSuite) getTokenWithProfile(user models.User) string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "height", "birth_date", "sex", "activity_level"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, user.Height, user.BirthDate, user.Sex, user.ActivityLevel),
+		)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) TestGetCalorieGoalSuggestionSuccessful() {
+	user := models.User{Height: 180, BirthDate: "1994-03-02", Sex: models.SexMale, ActivityLevel: models.ActivityModerate}
+	token := suite.getTokenWithProfile(user)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements` WHERE user_id = \\? AND kind = \\? ORDER BY timestamp desc,`measurements`.`id` LIMIT \\?").
+		WithArgs(float64(1), models.MeasurementWeight, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "kind", "value", "unit", "timestamp"}).
+				AddRow(3, 1, models.MeasurementWeight, 176.37, "lb", time.Now()),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/goal/suggestion?rate=-0.5", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.GoalSuggestion
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	age, _ := user.Age(time.Now())
+	bmr := 10*80 + 6.25*180 - 5*float64(age) + 5
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.InDelta(suite.T(), 80.0, responseBody.Weight, 0.01)
+	assert.Equal(suite.T(), age, responseBody.Age)
+	assert.InDelta(suite.T(), bmr, responseBody.BMR, 0.1)
+	assert.InDelta(suite.T(), bmr*1.55, responseBody.TDEE, 0.1)
+	assert.Equal(suite.T(), -0.5, responseBody.Rate)
+	// Half a kilo per week is a 550 kcal deficit per day
+	assert.InDelta(suite.T(), bmr*1.55-550, float64(responseBody.Calories), 1)
+	assert.False(suite.T(), responseBody.Limited)
+}
+
+func (suite *TestSuite) TestGetCalorieGoalSuggestionIsLimited() {
+	user := models.User{Height: 155, BirthDate: "1950-01-01", Sex: models.SexFemale, ActivityLevel: models.ActivitySedentary}
+	token := suite.getTokenWithProfile(user)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements`").
+		WithArgs(float64(1), models.MeasurementWeight, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "kind", "value", "unit", "timestamp"}).
+				AddRow(3, 1, models.MeasurementWeight, 50, "kg", time.Now()),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/goal/suggestion?rate=-1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.GoalSuggestion
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), uint(1200), responseBody.Calories)
+	assert.True(suite.T(), responseBody.Limited)
+}
+
+func (suite *TestSuite) TestGetCalorieGoalSuggestionRequiresProfile() {
+	token := suite.getTokenWithProfile(models.User{Height: 180, Sex: models.SexMale})
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/goal/suggestion", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The profile has no birth_date, activity_level", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetCalorieGoalSuggestionRequiresWeight() {
+	user := models.User{Height: 180, BirthDate: "1994-03-02", Sex: models.SexMale, ActivityLevel: models.ActivityModerate}
+	token := suite.getTokenWithProfile(user)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements`").
+		WithArgs(float64(1), models.MeasurementWeight, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "kind", "value", "unit", "timestamp"}))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/goal/suggestion", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "A weight measurement is needed to suggest a goal", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetCalorieGoalSuggestionWithBadRate() {
+	token := suite.getToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/goal/suggestion?rate=-3", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The rate query string must be a number of kg per week between -1 and 1", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetCalorieGoalSuggestionWithNonFiniteRate() {
+	for _, rate := range []string{"NaN", "Inf", "-Inf"} {
+		token := suite.getToken()
+
+		router := routes.SetupRouter()
+		w := httptest.NewRecorder()
+
+		req, _ := http.NewRequest("GET", "/user/goal/suggestion?rate="+url.QueryEscape(rate), nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(suite.T(), 400, w.Code, rate)
+	}
+}
+
+func (suite *TestSuite) TestGetCalorieGoalHistoryWithDatabaseError() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `calorie_goals` WHERE user_id = \\? ORDER BY effective_from desc").
+		WillReturnError(errors.New("connection lost"))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/goal/history", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "Failed to retrieve the calorie goals", responseBody.Message)
+}
+
+func TestRunSuite(t *testing.T) {
+	suite.Run(t, new(TestSuite))
+}
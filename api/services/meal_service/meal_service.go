@@ -0,0 +1,107 @@
+package mealservice
+
+import (
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/tokens"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MealExists tells whether name is a fixed meal or one of the custom meals
+// of the user.
+func MealExists(db *gorm.DB, userId any, name string) (bool, error) {
+	if models.IsMeal(name) {
+		return true, nil
+	}
+
+	var count int64
+
+	err := db.Model(&models.CustomMeal{}).Where("user_id = ? AND name = ?", userId, name).Count(&count).Error
+
+	return count > 0, err
+}
+
+func GetMeals(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	customMeals := []models.CustomMeal{}
+
+	if err := connection.Db.Where("user_id = ?", claims["id"]).Order("name").Find(&customMeals).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the meals")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"meals":        models.Meals,
+		"custom_meals": customMeals,
+	})
+}
+
+func PostMeal(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	var meal models.CustomMeal
+
+	if !apierrors.BindJSON(c, &meal) {
+		return
+	}
+
+	meal.ID = 0
+	meal.UserID = uint(claims["id"].(float64))
+
+	if models.IsMeal(meal.Name) {
+		apierrors.Respond(c, http.StatusConflict, "This name is not available")
+		return
+	}
+
+	if err := connection.Db.Create(&meal).Error; err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			apierrors.Respond(c, http.StatusConflict, "This name is not available")
+			return
+		}
+
+		apierrors.Internal(c, err, "Failed to save meal")
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, meal)
+}
+
+func DeleteMeal(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	id := c.Param("id")
+	userId := claims["id"]
+
+	var meal models.CustomMeal
+	result := connection.Db.Where("user_id = ?", userId).First(&meal, id)
+
+	if result.Error != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	var references int64
+
+	if err := connection.Db.Model(&models.FoodItem{}).Where("user_id = ? AND meal = ?", userId, meal.Name).Count(&references).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to delete record")
+		return
+	}
+
+	if references > 0 {
+		apierrors.Respond(c, http.StatusConflict, "This meal is used by diary entries and cannot be deleted")
+		return
+	}
+
+	if err := connection.Db.Delete(&meal).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to delete record")
+		return
+	}
+
+	c.IndentedJSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,287 @@
+package mealservice_test
+
+import (
+	"database/sql"
+	"diet-app-backend/api/routes"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/tests"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+)
+
+const email = "test.user@test.com"
+const firstName = "Joe"
+const lastName = "Doe"
+const password = "Str0ng-P@ssw0rd"
+
+var hashedPassword, _ = hashing.HashPassword(password)
+
+type TestSuite struct {
+	suite.Suite
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+func (suite *TestSuite) SetupTest() {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		suite.T().Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	dialector := mysql.New(mysql.Config{
+		DSN:                       "sqlmock_db_0",
+		DriverName:                "mysql",
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	})
+	connection.Connect(dialector)
+
+	suite.db = db
+	suite.mock = mock
+
+	config.LoadEnv("../../../.")
+}
+
+func (suite *TestSuite) TearDownTest() {
+	suite.db.Close()
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TestSuite) getToken() string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) TestGetMealsSuccessful() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `custom_meals` WHERE user_id = \\? ORDER BY name").
+		WithArgs(float64(1)).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name"}).
+				AddRow(1, 1, "pre-workout"),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/meal", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody struct {
+		Meals       []string
+		CustomMeals []models.CustomMeal `json:"custom_meals"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), models.Meals, responseBody.Meals)
+	assert.Len(suite.T(), responseBody.CustomMeals, 1)
+	assert.Equal(suite.T(), "pre-workout", responseBody.CustomMeals[0].Name)
+}
+
+func (suite *TestSuite) TestPostMealSuccessful() {
+	token := suite.getToken()
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `custom_meals`").
+		WithArgs(1, "pre-workout").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/meal", strings.NewReader(`{"name": "pre-workout"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.CustomMeal
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 201, w.Code)
+	assert.Equal(suite.T(), uint(1), responseBody.ID)
+	assert.Equal(suite.T(), "pre-workout", responseBody.Name)
+}
+
+func (suite *TestSuite) TestPostMealWithFixedMealName() {
+	token := suite.getToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/meal", strings.NewReader(`{"name": "lunch"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 409, w.Code)
+	assert.Equal(suite.T(), "This name is not available", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostMealWithoutAuthorization() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/meal", strings.NewReader(`{"name": "pre-workout"}`))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) TestDeleteMealUsedByDiaryEntries() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `custom_meals` WHERE user_id = \\? AND `custom_meals`.`id` = \\? ORDER BY `custom_meals`.`id` LIMIT \\?").
+		WithArgs(float64(1), "1", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name"}).
+				AddRow(1, 1, "pre-workout"),
+		)
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `food_items` WHERE user_id = \\? AND meal = \\?").
+		WithArgs(float64(1), "pre-workout").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/meal/1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 409, w.Code)
+	assert.Equal(suite.T(), "This meal is used by diary entries and cannot be deleted", responseBody.Message)
+}
+
+func (suite *TestSuite) TestDeleteMealSuccessful() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `custom_meals`").
+		WithArgs(float64(1), "1", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name"}).
+				AddRow(1, 1, "pre-workout"),
+		)
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `food_items`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^DELETE FROM `custom_meals` WHERE `custom_meals`.`id` = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/meal/1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 204, w.Code)
+}
+
+func (suite *TestSuite) TestGetMealsWithDatabaseError() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `custom_meals` WHERE user_id = \\? ORDER BY name").
+		WillReturnError(errors.New("connection lost"))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/meal", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "Failed to retrieve the meals", responseBody.Message)
+}
+
+func (suite *TestSuite) TestDeleteMealWithDatabaseError() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `custom_meals`").
+		WithArgs(float64(1), "1", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name"}).
+				AddRow(1, 1, "pre-workout"),
+		)
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `food_items`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^DELETE FROM `custom_meals`").
+		WillReturnError(errors.New("connection lost"))
+	suite.mock.ExpectRollback()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/meal/1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "Failed to delete record", responseBody.Message)
+}
+
+func TestRunSuite(t *testing.T) {
+	suite.Run(t, new(TestSuite))
+}
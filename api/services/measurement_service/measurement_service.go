@@ -0,0 +1,278 @@
+package measurementservice
+
+import (
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/authentication"
+	"diet-app-backend/util/dates"
+	"diet-app-backend/util/tokens"
+	"diet-app-backend/util/trends"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultDays is the number of days listed when no range is given, ending
+// with the current day.
+const defaultDays = 30
+
+const (
+	defaultWindow = 7
+	maxWindow     = 90
+)
+
+// GetMeasurements returns the measurements of the user between the from and
+// to query strings, which work like those of GET /user/food, optionally
+// restricted to one kind. Without a range, the last 30 days are returned.
+func GetMeasurements(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	from, to, ok := getRange(c, authentication.GetUser(c).Location())
+
+	if !ok {
+		return
+	}
+
+	query := connection.Db.Where("user_id = ? AND timestamp >= ? AND timestamp < ?", claims["id"], from, to)
+
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	measurements := []models.Measurement{}
+
+	if err := query.Order("timestamp").Find(&measurements).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to retrieve the measurements")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, measurements)
+}
+
+func GetMeasurement(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	var measurement models.Measurement
+	result := connection.Db.Where("id = ? AND user_id = ?", c.Param("id"), claims["id"]).First(&measurement)
+
+	if result.Error != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, measurement)
+}
+
+func PostMeasurement(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	var measurement models.Measurement
+
+	if !apierrors.BindJSON(c, &measurement) {
+		return
+	}
+
+	unit, ok := checkMeasurement(c, measurement.Kind, measurement.Unit)
+
+	if !ok {
+		return
+	}
+
+	measurement.ID = 0
+	measurement.UserID = uint(claims["id"].(float64))
+	measurement.Unit = unit
+
+	if err := connection.Db.Create(&measurement).Error; err != nil {
+		apierrors.Internal(c, err, "A measurement could not be created")
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, measurement)
+}
+
+// PutMeasurement updates the value, unit and timestamp of a measurement. Its
+// kind can't change, a measurement of another kind must be logged instead.
+func PutMeasurement(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	var updateMeasurement schemas.UpdateMeasurement
+
+	if !apierrors.BindJSON(c, &updateMeasurement) {
+		return
+	}
+
+	var measurement models.Measurement
+	result := connection.Db.Where("id = ? AND user_id = ?", c.Param("id"), claims["id"]).First(&measurement)
+
+	if result.Error != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	unit, ok := checkMeasurement(c, measurement.Kind, updateMeasurement.Unit)
+
+	if !ok {
+		return
+	}
+
+	measurement.Value = updateMeasurement.Value
+	measurement.Unit = unit
+	measurement.Timestamp = updateMeasurement.Timestamp
+
+	if err := connection.Db.Save(&measurement).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to update record")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, measurement)
+}
+
+func DeleteMeasurement(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	var measurement models.Measurement
+	result := connection.Db.Where("id = ? AND user_id = ?", c.Param("id"), claims["id"]).First(&measurement)
+
+	if result.Error != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if err := connection.Db.Delete(&measurement).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to delete record")
+		return
+	}
+
+	c.IndentedJSON(http.StatusNoContent, nil)
+}
+
+// GetMeasurementTrend smooths a kind of measurement, weight by default, with
+// a moving average over the number of days of the window query string, so
+// that day to day noise such as water weight stands out less than the trend.
+// Values are converted to the unit query string, or else to the unit of the
+// latest measurement.
+func GetMeasurementTrend(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	kind := c.DefaultQuery("kind", models.MeasurementWeight)
+	units, ok := models.MeasurementUnits[kind]
+
+	if !ok {
+		apierrors.Respond(c, http.StatusBadRequest, fmt.Sprintf("The kind query string must be one of %s", strings.Join(models.MeasurementKinds, ", ")))
+		return
+	}
+
+	unit := c.Query("unit")
+
+	if unit != "" && !slices.Contains(units, unit) {
+		apierrors.Respond(c, http.StatusBadRequest, fmt.Sprintf("The unit query string must be one of %s", strings.Join(units, ", ")))
+		return
+	}
+
+	window := defaultWindow
+
+	if windowStr, exists := c.GetQuery("window"); exists {
+		var err error
+
+		if window, err = strconv.Atoi(windowStr); err != nil || window < 1 || window > maxWindow {
+			apierrors.Respond(c, http.StatusBadRequest, fmt.Sprintf("The window query string must be a number of days between 1 and %d", maxWindow))
+			return
+		}
+	}
+
+	location := authentication.GetUser(c).Location()
+	from, to, ok := getRange(c, location)
+
+	if !ok {
+		return
+	}
+
+	// The days before the range are needed to average its first days
+	windowStart := dates.StartOfDay(from, location).AddDate(0, 0, 1-window)
+
+	var measurements []models.Measurement
+	connection.Db.Where("user_id = ? AND kind = ? AND timestamp >= ? AND timestamp < ?", claims["id"], kind, windowStart, to).
+		Order("timestamp").
+		Find(&measurements)
+
+	if unit == "" {
+		unit = units[0]
+
+		if len(measurements) > 0 {
+			unit = measurements[len(measurements)-1].Unit
+		}
+	}
+
+	samples := []trends.Sample{}
+
+	for _, measurement := range measurements {
+		samples = append(samples, trends.Sample{
+			Time:  measurement.Timestamp,
+			Value: models.ConvertMeasurement(measurement.Value, measurement.Unit, unit),
+		})
+	}
+
+	trend := schemas.MeasurementTrend{Kind: kind, Unit: unit, Window: window, Points: []schemas.TrendPoint{}}
+
+	for _, day := range trends.MovingAverage(samples, window, location) {
+		if day.Date.Before(dates.StartOfDay(from, location)) {
+			continue
+		}
+
+		trend.Points = append(trend.Points, schemas.TrendPoint{
+			Date:    day.Date.Format(time.DateOnly),
+			Value:   day.Mean,
+			Average: day.Average,
+		})
+	}
+
+	c.IndentedJSON(http.StatusOK, trend)
+}
+
+// checkMeasurement responds with an error and returns false when the kind or
+// the unit of a measurement is unknown. It returns the unit to store, which
+// is the default unit of the kind when none is given.
+func checkMeasurement(c *gin.Context, kind string, unit string) (string, bool) {
+	units, ok := models.MeasurementUnits[kind]
+
+	if !ok {
+		apierrors.Respond(c, http.StatusBadRequest, fmt.Sprintf("The kind must be one of %s", strings.Join(models.MeasurementKinds, ", ")))
+		return "", false
+	}
+
+	if unit == "" {
+		return units[0], true
+	}
+
+	if !slices.Contains(units, unit) {
+		apierrors.Respond(c, http.StatusBadRequest, fmt.Sprintf("The unit of a %s measurement must be one of %s", kind, strings.Join(units, ", ")))
+		return "", false
+	}
+
+	return unit, true
+}
+
+// getRange reads the from and to query strings, responding with an error and
+// returning false when they are invalid. A missing from defaults to 30 days
+// before the current day, and a missing to to the end of the current day.
+func getRange(c *gin.Context, location *time.Location) (from time.Time, to time.Time, ok bool) {
+	today := dates.StartOfDay(time.Now(), location)
+
+	from, to, err := dates.ParseRange(c.Query("from"), c.Query("to"), location, today.AddDate(0, 0, 1-defaultDays), func(time.Time) time.Time {
+		return dates.NextDay(today)
+	})
+
+	if err != nil {
+		apierrors.Respond(c, http.StatusBadRequest, err.Error())
+		return from, to, false
+	}
+
+	return from, to, true
+}
@@ -0,0 +1,423 @@
+package measurementservice_test
+
+import (
+	"database/sql"
+	"diet-app-backend/api/routes"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/tests"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+)
+
+const email = "test.user@test.com"
+const firstName = "Joe"
+const lastName = "Doe"
+const password = "Str0ng-P@ssw0rd"
+
+var hashedPassword, _ = hashing.HashPassword(password)
+
+type TestSuite struct {
+	suite.Suite
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+func (suite *TestSuite) SetupTest() {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		suite.T().Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	dialector := mysql.New(mysql.Config{
+		DSN:                       "sqlmock_db_0",
+		DriverName:                "mysql",
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	})
+	connection.Connect(dialector)
+
+	suite.db = db
+	suite.mock = mock
+
+	config.LoadEnv("../../../.")
+}
+
+func (suite *TestSuite) TearDownTest() {
+	suite.db.Close()
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TestSuite) getToken() string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) TestGetMeasurementsSuccessful() {
+	token := suite.getToken()
+
+	from := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements` WHERE \\(user_id = \\? AND timestamp >= \\? AND timestamp < \\?\\) AND kind = \\? ORDER BY timestamp").
+		WithArgs(float64(1), from, from.AddDate(0, 1, 0), models.MeasurementWeight).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "kind", "value", "unit", "timestamp"}).
+				AddRow(1, 1, models.MeasurementWeight, 80.4, "kg", from.Add(7*time.Hour)).
+				AddRow(2, 1, models.MeasurementWeight, 79.9, "kg", from.AddDate(0, 0, 1).Add(7*time.Hour)),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/measurement?kind=weight&from=2024-05-01&to=2024-05-31", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody []models.Measurement
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Len(suite.T(), responseBody, 2)
+	assert.Equal(suite.T(), 80.4, responseBody[0].Value)
+	assert.Equal(suite.T(), "kg", responseBody[0].Unit)
+	assert.Equal(suite.T(), 79.9, responseBody[1].Value)
+}
+
+func (suite *TestSuite) TestGetMeasurementNotFound() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements` WHERE id = \\? AND user_id = \\? ORDER BY `measurements`.`id` LIMIT \\?").
+		WithArgs("3", float64(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "kind", "value", "unit", "timestamp"}))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/measurement/3", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 404, w.Code)
+	assert.Equal(suite.T(), "Not found", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostMeasurementSuccessful() {
+	token := suite.getToken()
+
+	timestamp := time.Date(2024, time.May, 10, 7, 0, 0, 0, time.UTC)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `measurements`").
+		WithArgs(1, models.MeasurementWaist, 84.5, "cm", timestamp).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"kind": "waist", "value": 84.5, "timestamp": "2024-05-10T07:00:00Z"}`
+
+	req, _ := http.NewRequest("POST", "/user/measurement", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.Measurement
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 201, w.Code)
+	assert.Equal(suite.T(), uint(1), responseBody.ID)
+	assert.Equal(suite.T(), uint(1), responseBody.UserID)
+	// The unit defaults to the metric one
+	assert.Equal(suite.T(), "cm", responseBody.Unit)
+}
+
+func (suite *TestSuite) TestPostMeasurementWithUnknownKind() {
+	token := suite.getToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"kind": "height", "value": 180, "timestamp": "2024-05-10T07:00:00Z"}`
+
+	req, _ := http.NewRequest("POST", "/user/measurement", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The kind must be one of weight, body_fat, waist, hips, chest, neck, arm, thigh", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostMeasurementWithWrongUnit() {
+	token := suite.getToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"kind": "weight", "value": 80, "unit": "cm", "timestamp": "2024-05-10T07:00:00Z"}`
+
+	req, _ := http.NewRequest("POST", "/user/measurement", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The unit of a weight measurement must be one of kg, lb", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostMeasurementRequiresValue() {
+	token := suite.getToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"kind": "weight", "value": 0, "timestamp": "2024-05-10T07:00:00Z"}`
+
+	req, _ := http.NewRequest("POST", "/user/measurement", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestPostMeasurementWithoutAuthorization() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"kind": "weight", "value": 80, "timestamp": "2024-05-10T07:00:00Z"}`
+
+	req, _ := http.NewRequest("POST", "/user/measurement", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPutMeasurementSuccessful() {
+	token := suite.getToken()
+
+	timestamp := time.Date(2024, time.May, 10, 7, 0, 0, 0, time.UTC)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements` WHERE id = \\? AND user_id = \\? ORDER BY `measurements`.`id` LIMIT \\?").
+		WithArgs("1", float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "kind", "value", "unit", "timestamp"}).
+				AddRow(1, 1, models.MeasurementWeight, 80.4, "kg", timestamp),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `measurements` SET `user_id`=\\?,`kind`=\\?,`value`=\\?,`unit`=\\?,`timestamp`=\\? WHERE `id` = \\?").
+		WithArgs(1, models.MeasurementWeight, 177.2, "lb", timestamp, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"value": 177.2, "unit": "lb", "timestamp": "2024-05-10T07:00:00Z"}`
+
+	req, _ := http.NewRequest("PUT", "/user/measurement/1", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.Measurement
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), models.MeasurementWeight, responseBody.Kind)
+	assert.Equal(suite.T(), 177.2, responseBody.Value)
+	assert.Equal(suite.T(), "lb", responseBody.Unit)
+}
+
+func (suite *TestSuite) TestDeleteMeasurementSuccessful() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements` WHERE id = \\? AND user_id = \\? ORDER BY `measurements`.`id` LIMIT \\?").
+		WithArgs("1", float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "kind", "value", "unit", "timestamp"}).
+				AddRow(1, 1, models.MeasurementWeight, 80.4, "kg", time.Now()),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^DELETE FROM `measurements` WHERE `measurements`.`id` = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/measurement/1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 204, w.Code)
+}
+
+func (suite *TestSuite) TestGetMeasurementTrendSuccessful() {
+	token := suite.getToken()
+
+	from := time.Date(2024, time.May, 3, 0, 0, 0, 0, time.UTC)
+
+	// The two days before the range are loaded for a window of 3 days
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements` WHERE user_id = \\? AND kind = \\? AND timestamp >= \\? AND timestamp < \\? ORDER BY timestamp").
+		WithArgs(float64(1), models.MeasurementWeight, from.AddDate(0, 0, -2), from.AddDate(0, 0, 2)).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "kind", "value", "unit", "timestamp"}).
+				AddRow(1, 1, models.MeasurementWeight, 81, "kg", from.AddDate(0, 0, -2).Add(7*time.Hour)).
+				AddRow(2, 1, models.MeasurementWeight, 82, "kg", from.AddDate(0, 0, -1).Add(7*time.Hour)).
+				AddRow(3, 1, models.MeasurementWeight, 176.37, "lb", from.Add(7*time.Hour)).
+				AddRow(4, 1, models.MeasurementWeight, 79, "kg", from.AddDate(0, 0, 1).Add(7*time.Hour)),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/measurement/trend?from=2024-05-03&to=2024-05-04&window=3", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.MeasurementTrend
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), models.MeasurementWeight, responseBody.Kind)
+	// The unit of the latest measurement
+	assert.Equal(suite.T(), "kg", responseBody.Unit)
+	assert.Equal(suite.T(), 3, responseBody.Window)
+	assert.Len(suite.T(), responseBody.Points, 2)
+
+	assert.Equal(suite.T(), "2024-05-03", responseBody.Points[0].Date)
+	assert.InDelta(suite.T(), 80.0, responseBody.Points[0].Value, 0.01)
+	assert.InDelta(suite.T(), 81.0, responseBody.Points[0].Average, 0.01)
+
+	assert.Equal(suite.T(), "2024-05-04", responseBody.Points[1].Date)
+	assert.InDelta(suite.T(), 79.0, responseBody.Points[1].Value, 0.01)
+	assert.InDelta(suite.T(), 80.33, responseBody.Points[1].Average, 0.01)
+}
+
+func (suite *TestSuite) TestGetMeasurementTrendWithBadWindow() {
+	token := suite.getToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/measurement/trend?window=0", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The window query string must be a number of days between 1 and 90", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetMeasurementsWithDatabaseError() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements`").
+		WillReturnError(errors.New("connection lost"))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/measurement", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "Failed to retrieve the measurements", responseBody.Message)
+}
+
+func (suite *TestSuite) TestDeleteMeasurementWithDatabaseError() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `measurements` WHERE id = \\? AND user_id = \\?").
+		WithArgs("1", float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "kind", "value", "unit", "timestamp"}).
+				AddRow(1, 1, models.MeasurementWeight, 80.4, "kg", time.Now()),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^DELETE FROM `measurements`").
+		WillReturnError(errors.New("connection lost"))
+	suite.mock.ExpectRollback()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/measurement/1", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 500, w.Code)
+	assert.Equal(suite.T(), "Failed to delete record", responseBody.Message)
+}
+
+func TestRunSuite(t *testing.T) {
+	suite.Run(t, new(TestSuite))
+}
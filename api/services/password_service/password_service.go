@@ -0,0 +1,181 @@
+package passwordservice
+
+import (
+	tokenservice "diet-app-backend/api/services/token_service"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/mail"
+	"diet-app-backend/util/passwords"
+	"diet-app-backend/util/tokens"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errResetTokenUsed = errors.New("password reset token already used")
+
+// PostForgotPassword mails a password reset link to the user, at most once
+// per PASSWORD_RESET_RESEND_INTERVAL for each account. The response is the
+// same whether or not the email belongs to an account, and the reset token
+// is created and mailed in the background so that the response time doesn't
+// give it away either.
+func PostForgotPassword(c *gin.Context) {
+	var forgotPassword schemas.ForgotPassword
+
+	if !apierrors.BindJSON(c, &forgotPassword) {
+		return
+	}
+
+	var user models.User
+
+	if err := connection.Db.Where("email = ?", forgotPassword.Email).First(&user).Error; err == nil {
+		go func() {
+			if err := sendResetToken(user); err != nil {
+				log.Printf("failed to send a password reset mail: %v", err)
+			}
+		}()
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(err)
+	}
+
+	c.IndentedJSON(http.StatusAccepted, gin.H{
+		"message": "If this email belongs to an account, a reset link was sent to it",
+	})
+}
+
+func sendResetToken(user models.User) error {
+	now := time.Now()
+
+	// The condition makes concurrent requests send a single mail
+	result := connection.Db.Model(&user).
+		Where("password_reset_sent_at IS NULL OR password_reset_sent_at < ?", now.Add(-config.AppConfig.ResetResend)).
+		Update("password_reset_sent_at", now)
+
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	token, err := tokens.GenerateOpaqueToken()
+
+	if err != nil {
+		return err
+	}
+
+	result = connection.Db.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: tokens.HashOpaqueToken(token),
+		ExpiresAt: time.Now().Add(config.AppConfig.PasswordResetTtl),
+	})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.AppConfig.FrontEndUrl, url.QueryEscape(token))
+
+	message := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nFollow this link to choose a new password:\n\n%s\n\n"+
+				"The link expires in %s. If you didn't ask for it, you can ignore this mail.\n",
+			user.FirstName, link, config.AppConfig.PasswordResetTtl,
+		),
+	}
+
+	return mail.Send(message)
+}
+
+// PostResetPassword sets a new password with a token mailed by
+// PostForgotPassword. The token can only be used once, along with every other
+// token of the user, and every session of the user is logged out.
+func PostResetPassword(c *gin.Context) {
+	var resetPassword schemas.ResetPassword
+
+	if !apierrors.BindJSON(c, &resetPassword) {
+		return
+	}
+
+	var resetToken models.PasswordResetToken
+
+	err := connection.Db.Where(
+		"token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		tokens.HashOpaqueToken(resetPassword.Token), time.Now(),
+	).First(&resetToken).Error
+
+	if err != nil {
+		apierrors.Respond(c, http.StatusBadRequest, "The reset token is invalid or expired")
+		return
+	}
+
+	var user models.User
+
+	if err := connection.Db.First(&user, resetToken.UserID).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to reset the password")
+		return
+	}
+
+	if details := passwords.Default.Check("password", resetPassword.Password, user.Email, user.FirstName, user.LastName); len(details) > 0 {
+		apierrors.Invalid(c, details)
+		return
+	}
+
+	hashedPassword, err := hashing.HashPassword(resetPassword.Password)
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to reset the password")
+		return
+	}
+
+	err = connection.Db.Transaction(func(tx *gorm.DB) error {
+		// Only one of two concurrent requests with the same token succeeds
+		result := tx.Model(&resetToken).Where("used_at IS NULL").Update("used_at", time.Now())
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errResetTokenUsed
+		}
+
+		// The other links mailed to the user stop working with this one
+		err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", resetToken.UserID).
+			Update("used_at", time.Now()).Error
+
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).Update("password", hashedPassword).Error
+
+		if err != nil {
+			return err
+		}
+
+		return tokenservice.RevokeAllTokens(tx, resetToken.UserID)
+	})
+
+	if errors.Is(err, errResetTokenUsed) {
+		apierrors.Respond(c, http.StatusBadRequest, "The reset token is invalid or expired")
+		return
+	}
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to reset the password")
+		return
+	}
+
+	c.IndentedJSON(http.StatusNoContent, nil)
+}
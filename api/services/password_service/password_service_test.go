@@ -0,0 +1,301 @@
+package passwordservice_test
+
+import (
+	"database/sql"
+	"diet-app-backend/api/routes"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/mail"
+	"diet-app-backend/util/tests"
+	"diet-app-backend/util/tokens"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+)
+
+const email = "test.user@test.com"
+const firstName = "Joe"
+const lastName = "Doe"
+const password = "Str0ng-P@ssw0rd"
+
+var hashedPassword, _ = hashing.HashPassword(password)
+
+type TestSuite struct {
+	suite.Suite
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+func (suite *TestSuite) SetupTest() {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		suite.T().Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	dialector := mysql.New(mysql.Config{
+		DSN:                       "sqlmock_db_0",
+		DriverName:                "mysql",
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	})
+	connection.Connect(dialector)
+
+	suite.db = db
+	suite.mock = mock
+
+	config.LoadEnv("../../../.")
+}
+
+func (suite *TestSuite) TearDownTest() {
+	suite.db.Close()
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TestSuite) TestPostForgotPasswordSuccessful() {
+	mailer := mail.NewMemoryMailer()
+	mail.Default = mailer
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+	suite.expectResetThrottle().WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `password_reset_tokens`").
+		WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/password/forgot", strings.NewReader(fmt.Sprintf(`{"email": "%s"}`, email)))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody map[string]string
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 202, w.Code)
+	assert.Equal(suite.T(), "If this email belongs to an account, a reset link was sent to it", responseBody["message"])
+
+	assert.Eventually(suite.T(), func() bool { return len(mailer.Messages()) == 1 }, time.Second, 10*time.Millisecond)
+
+	message := mailer.Messages()[0]
+
+	assert.Equal(suite.T(), email, message.To)
+	assert.Contains(suite.T(), message.Body, config.AppConfig.FrontEndUrl+"/reset-password?token=")
+}
+
+func (suite *TestSuite) expectResetThrottle() *sqlmock.ExpectedExec {
+	suite.mock.ExpectBegin()
+
+	return suite.mock.ExpectExec(
+		"^UPDATE `users` SET `password_reset_sent_at`=\\? "+
+			"WHERE \\(password_reset_sent_at IS NULL OR password_reset_sent_at < \\?\\) AND `id` = \\?",
+	).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1)
+}
+
+func (suite *TestSuite) TestPostForgotPasswordThrottled() {
+	mailer := mail.NewMemoryMailer()
+	mail.Default = mailer
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+	// A link was sent less than PASSWORD_RESET_RESEND_INTERVAL ago
+	suite.expectResetThrottle().WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/password/forgot", strings.NewReader(fmt.Sprintf(`{"email": "%s"}`, email)))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 202, w.Code)
+	assert.Never(suite.T(), func() bool { return len(mailer.Messages()) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+}
+
+func (suite *TestSuite) TestPostForgotPasswordWithUnknownEmail() {
+	mailer := mail.NewMemoryMailer()
+	mail.Default = mailer
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs("unknown@test.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/password/forgot", strings.NewReader(`{"email": "unknown@test.com"}`))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody map[string]string
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	// The response doesn't tell that no account has this email
+	assert.Equal(suite.T(), 202, w.Code)
+	assert.Equal(suite.T(), "If this email belongs to an account, a reset link was sent to it", responseBody["message"])
+	assert.Never(suite.T(), func() bool { return len(mailer.Messages()) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+}
+
+func (suite *TestSuite) expectResetTokenLookup(token string) *sqlmock.ExpectedQuery {
+	return suite.mock.ExpectQuery(
+		"^SELECT \\* FROM `password_reset_tokens` WHERE token_hash = \\? AND used_at IS NULL AND expires_at > \\? "+
+			"ORDER BY `password_reset_tokens`.`id` LIMIT \\?",
+	).
+		WithArgs(tokens.HashOpaqueToken(token), sqlmock.AnyArg(), 1)
+}
+
+func (suite *TestSuite) expectUserLookup() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(1, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+}
+
+func (suite *TestSuite) TestPostResetPasswordSuccessful() {
+	suite.expectResetTokenLookup("reset-token").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "token_hash", "expires_at"}).
+				AddRow(3, 1, tokens.HashOpaqueToken("reset-token"), time.Now().Add(time.Hour)),
+		)
+	suite.expectUserLookup()
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `password_reset_tokens` SET `used_at`=\\? WHERE used_at IS NULL AND `id` = \\?").
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^UPDATE `password_reset_tokens` SET `used_at`=\\? WHERE user_id = \\? AND used_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectExec("^UPDATE `users` SET `password`=\\? WHERE id = \\?").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^SAVEPOINT").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectExec("^UPDATE `users` SET `token_version`=token_version \\+ 1 WHERE id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^UPDATE `refresh_tokens` SET `revoked_at`=\\? WHERE user_id = \\? AND revoked_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"token": "reset-token", "password": "An0ther-P@ssw0rd"}`
+
+	req, _ := http.NewRequest("POST", "/password/reset", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 204, w.Code)
+}
+
+func (suite *TestSuite) TestPostResetPasswordWithInvalidToken() {
+	suite.expectResetTokenLookup("expired-token").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token_hash", "expires_at"}))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"token": "expired-token", "password": "An0ther-P@ssw0rd"}`
+
+	req, _ := http.NewRequest("POST", "/password/reset", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The reset token is invalid or expired", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostResetPasswordWithTokenUsedConcurrently() {
+	suite.expectResetTokenLookup("reset-token").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "token_hash", "expires_at"}).
+				AddRow(3, 1, tokens.HashOpaqueToken("reset-token"), time.Now().Add(time.Hour)),
+		)
+	suite.expectUserLookup()
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `password_reset_tokens` SET `used_at`=\\? WHERE used_at IS NULL AND `id` = \\?").
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"token": "reset-token", "password": "An0ther-P@ssw0rd"}`
+
+	req, _ := http.NewRequest("POST", "/password/reset", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The reset token is invalid or expired", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostResetPasswordWithWeakPassword() {
+	suite.expectResetTokenLookup("reset-token").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "token_hash", "expires_at"}).
+				AddRow(3, 1, tokens.HashOpaqueToken("reset-token"), time.Now().Add(time.Hour)),
+		)
+	suite.expectUserLookup()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"token": "reset-token", "password": "joe-doe-2024"}`
+
+	req, _ := http.NewRequest("POST", "/password/reset", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The request body is invalid", responseBody.Message)
+	assert.Equal(suite.T(), []apierrors.FieldError{
+		{Field: "password", Rule: "personal_info", Message: "password must not contain your email or your name"},
+	}, responseBody.Details)
+}
+
+func TestRunSuite(t *testing.T) {
+	suite.Run(t, new(TestSuite))
+}
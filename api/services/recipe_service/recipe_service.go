@@ -0,0 +1,258 @@
+package recipeservice
+
+import (
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/search"
+	"diet-app-backend/util/tokens"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errInvalidIngredients = errors.New("invalid ingredients")
+
+func GetRecipe(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	id := c.Param("id")
+	userId := claims["id"]
+
+	var recipe models.Food
+	result := connection.Db.Preload("Ingredients").
+		Where("user_id = ? AND servings > 0", userId).
+		First(&recipe, id)
+
+	if result.Error != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, recipe)
+}
+
+func PostRecipe(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	userId := uint(claims["id"].(float64))
+
+	var recipeData schemas.Recipe
+
+	if !apierrors.BindJSON(c, &recipeData) {
+		return
+	}
+
+	recipe := models.Food{
+		UserID:      userId,
+		Name:        recipeData.Name,
+		Servings:    recipeData.Servings,
+		Ingredients: recipeData.Ingredients,
+	}
+
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		if err := applyIngredients(tx, &recipe); err != nil {
+			return err
+		}
+
+		return tx.Create(&recipe).Error
+	})
+
+	if err != nil {
+		handleSaveError(c, err)
+		return
+	}
+
+	search.Upsert(recipe)
+
+	c.IndentedJSON(http.StatusCreated, recipe)
+}
+
+func PutRecipe(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	id := c.Param("id")
+	userId := claims["id"]
+
+	var recipeData schemas.Recipe
+
+	if !apierrors.BindJSON(c, &recipeData) {
+		return
+	}
+
+	var recipe models.Food
+	result := connection.Db.Where("user_id = ? AND servings > 0", userId).First(&recipe, id)
+
+	if result.Error != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	recipe.Name = recipeData.Name
+	recipe.Servings = recipeData.Servings
+	recipe.Ingredients = recipeData.Ingredients
+
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		if err := applyIngredients(tx, &recipe); err != nil {
+			return err
+		}
+
+		if err := tx.Where("recipe_id = ?", recipe.ID).Delete(&models.RecipeIngredient{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Save(&recipe).Error
+	})
+
+	if err != nil {
+		handleSaveError(c, err)
+		return
+	}
+
+	search.Upsert(recipe)
+
+	c.IndentedJSON(http.StatusOK, recipe)
+}
+
+func DeleteRecipe(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	id := c.Param("id")
+	userId := claims["id"]
+
+	var recipe models.Food
+	result := connection.Db.Where("user_id = ? AND servings > 0", userId).First(&recipe, id)
+
+	if result.Error != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	var references int64
+
+	if err := connection.Db.Model(&models.FoodItem{}).Where("food_id = ?", recipe.ID).Count(&references).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to delete record")
+		return
+	}
+
+	if references > 0 {
+		apierrors.Respond(c, http.StatusConflict, "This food is used by diary entries and cannot be deleted")
+		return
+	}
+
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("recipe_id = ?", recipe.ID).Delete(&models.RecipeIngredient{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&recipe).Error
+	})
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to delete record")
+		return
+	}
+
+	search.Remove(recipe.ID)
+
+	c.IndentedJSON(http.StatusNoContent, nil)
+}
+
+// RefreshRecipesUsing recomputes the values of the recipes that have the
+// given food as an ingredient. It must be called whenever a food changes,
+// since the values of recipes are stored rather than computed on read.
+func RefreshRecipesUsing(tx *gorm.DB, foodId uint) error {
+	var recipes []models.Food
+
+	result := tx.Preload("Ingredients").
+		Where("id IN (?)", tx.Model(&models.RecipeIngredient{}).Select("recipe_id").Where("food_id = ?", foodId)).
+		Find(&recipes)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, recipe := range recipes {
+		foods, err := loadIngredientFoods(tx, recipe.Ingredients)
+
+		if err != nil {
+			return err
+		}
+
+		recipe.ApplyIngredients(foods)
+
+		err = tx.Model(&recipe).
+			Select("calories", "portion", "protein", "carbohydrates", "fat", "fiber", "sugar", "sodium").
+			Updates(&recipe).Error
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyIngredients checks that the ingredients of a recipe are foods its
+// owner can see, and derives the values of the recipe from them. Recipes
+// can't be used as ingredients.
+func applyIngredients(tx *gorm.DB, recipe *models.Food) error {
+	foods, err := loadIngredientFoods(
+		tx.Scopes(models.VisibleFoods(recipe.UserID)).Where("servings = 0"),
+		recipe.Ingredients,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	for _, ingredient := range recipe.Ingredients {
+		if _, ok := foods[ingredient.FoodID]; !ok {
+			return errInvalidIngredients
+		}
+	}
+
+	recipe.ApplyIngredients(foods)
+
+	return nil
+}
+
+func loadIngredientFoods(db *gorm.DB, ingredients []models.RecipeIngredient) (map[uint]models.Food, error) {
+	ids := make([]uint, 0, len(ingredients))
+
+	for _, ingredient := range ingredients {
+		ids = append(ids, ingredient.FoodID)
+	}
+
+	var foods []models.Food
+
+	if err := db.Where("id IN ?", ids).Find(&foods).Error; err != nil {
+		return nil, err
+	}
+
+	foodsById := make(map[uint]models.Food, len(foods))
+
+	for _, food := range foods {
+		foodsById[food.ID] = food
+	}
+
+	return foodsById, nil
+}
+
+func handleSaveError(c *gin.Context, err error) {
+	if errors.Is(err, errInvalidIngredients) {
+		apierrors.Respond(c, http.StatusBadRequest, "Ingredients must be existing foods that are not recipes")
+		return
+	}
+
+	if strings.Contains(err.Error(), "Duplicate entry") {
+		apierrors.Respond(c, http.StatusConflict, "This name is not available")
+		return
+	}
+
+	apierrors.Internal(c, err, "Failed to save recipe")
+}
@@ -0,0 +1,280 @@
+package recipeservice_test
+
+import (
+	"database/sql"
+	"diet-app-backend/api/routes"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/tests"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+)
+
+const email = "test.user@test.com"
+const firstName = "Joe"
+const lastName = "Doe"
+const password = "Str0ng-P@ssw0rd"
+
+const recipeJson = `{
+	"name": "Pasta with tomato sauce",
+	"servings": 2,
+	"ingredients": [
+		{"food_id": 1, "quantity": 160},
+		{"food_id": 2, "quantity": 200}
+	]
+}`
+
+var hashedPassword, _ = hashing.HashPassword(password)
+
+type TestSuite struct {
+	suite.Suite
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+func (suite *TestSuite) SetupTest() {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		suite.T().Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	dialector := mysql.New(mysql.Config{
+		DSN:                       "sqlmock_db_0",
+		DriverName:                "mysql",
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	})
+	connection.Connect(dialector)
+
+	suite.db = db
+	suite.mock = mock
+
+	config.LoadEnv("../../../.")
+}
+
+func (suite *TestSuite) TearDownTest() {
+	suite.db.Close()
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (suite *TestSuite) getToken() string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) TestPostRecipeSuccessful() {
+	token := suite.getToken()
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE servings = 0 AND id IN \\(\\?,\\?\\) AND foods.user_id IN \\(\\?,\\?\\)").
+		WithArgs(1, 2, 0, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion", "protein"}).
+				AddRow(1, 0, "Pasta", 193, 80, 5.6).
+				AddRow(2, 0, "Tomato Sauce", 34, 100, 1.5),
+		)
+	suite.mock.ExpectExec("INSERT INTO `foods`").
+		WithArgs(1, "Pasta with tomato sauce", 227, 180, 7.1, 0.0, 0.0, 0.0, 0.0, 0.0, 2).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	suite.mock.ExpectExec("INSERT INTO `recipe_ingredients`").
+		WithArgs(3, 1, 160, 3, 2, 200).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/recipe", strings.NewReader(recipeJson))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.Food
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 201, w.Code)
+	assert.Equal(suite.T(), uint(3), responseBody.ID)
+	assert.Equal(suite.T(), uint(1), responseBody.UserID)
+	assert.Equal(suite.T(), uint(2), responseBody.Servings)
+	// (193 * 160 / 80 + 34 * 200 / 100) / 2 servings
+	assert.Equal(suite.T(), 227, responseBody.Calories)
+	// (160 + 200) / 2 servings
+	assert.Equal(suite.T(), 180, responseBody.Portion)
+	assert.InDelta(suite.T(), 7.1, responseBody.Protein, 0.001)
+	assert.Len(suite.T(), responseBody.Ingredients, 2)
+}
+
+func (suite *TestSuite) TestPostRecipeWithInvalidIngredient() {
+	token := suite.getToken()
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE servings = 0 AND id IN \\(\\?,\\?\\) AND foods.user_id IN \\(\\?,\\?\\)").
+		WithArgs(1, 2, 0, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion"}).
+				AddRow(1, 0, "Pasta", 193, 80),
+		)
+	suite.mock.ExpectRollback()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/recipe", strings.NewReader(recipeJson))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "Ingredients must be existing foods that are not recipes", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostRecipeRequiresIngredients() {
+	token := suite.getToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/recipe", strings.NewReader(`{"name": "Nothing", "servings": 1, "ingredients": []}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestPostRecipeWithoutAuthorization() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/recipe", strings.NewReader(recipeJson))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) TestGetRecipeSuccessful() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE \\(user_id = \\? AND servings > 0\\) AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(float64(1), "3", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion", "servings"}).
+				AddRow(3, 1, "Pasta with tomato sauce", 227, 180, 2),
+		)
+	suite.mock.ExpectQuery("^SELECT \\* FROM `recipe_ingredients` WHERE `recipe_ingredients`.`recipe_id` = \\?").
+		WithArgs(3).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "recipe_id", "food_id", "quantity"}).
+				AddRow(1, 3, 1, 160).
+				AddRow(2, 3, 2, 200),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/recipe/3", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.Food
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), "Pasta with tomato sauce", responseBody.Name)
+	assert.Len(suite.T(), responseBody.Ingredients, 2)
+	assert.Equal(suite.T(), uint(1), responseBody.Ingredients[0].FoodID)
+	assert.Equal(suite.T(), uint(160), responseBody.Ingredients[0].Quantity)
+}
+
+func (suite *TestSuite) TestGetRecipeNotFound() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE \\(user_id = \\? AND servings > 0\\) AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(float64(1), "3", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion", "servings"}),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user/recipe/3", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 404, w.Code)
+	assert.Equal(suite.T(), "Not found", responseBody.Message)
+}
+
+func (suite *TestSuite) TestDeleteRecipeUsedByDiaryEntries() {
+	token := suite.getToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `foods` WHERE \\(user_id = \\? AND servings > 0\\) AND `foods`.`id` = \\? ORDER BY `foods`.`id` LIMIT \\?").
+		WithArgs(float64(1), "3", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "name", "calories", "portion", "servings"}).
+				AddRow(3, 1, "Pasta with tomato sauce", 227, 180, 2),
+		)
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `food_items` WHERE food_id = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/recipe/3", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 409, w.Code)
+}
+
+func TestRunSuite(t *testing.T) {
+	suite.Run(t, new(TestSuite))
+}
@@ -0,0 +1,218 @@
+package tokenservice
+
+import (
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/revocation"
+	"diet-app-backend/util/tokens"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// IssueTokens signs an access token for the user and persists a new refresh
+// token in the given family. An empty familyId starts a new family, which is
+// what happens on login.
+func IssueTokens(db *gorm.DB, user models.User, familyId string) (schemas.TokenPair, error) {
+	accessToken, err := user.IssueToken()
+
+	if err != nil {
+		return schemas.TokenPair{}, err
+	}
+
+	refreshToken, err := tokens.GenerateOpaqueToken()
+
+	if err != nil {
+		return schemas.TokenPair{}, err
+	}
+
+	if familyId == "" {
+		familyId, err = tokens.GenerateOpaqueToken()
+
+		if err != nil {
+			return schemas.TokenPair{}, err
+		}
+	}
+
+	result := db.Create(&models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyId,
+		TokenHash: tokens.HashOpaqueToken(refreshToken),
+		ExpiresAt: time.Now().Add(config.AppConfig.JwtRefreshTokenTtl),
+	})
+
+	if result.Error != nil {
+		return schemas.TokenPair{}, result.Error
+	}
+
+	return schemas.TokenPair{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(config.AppConfig.JwtAccessTokenTtl.Seconds()),
+	}, nil
+}
+
+func Refresh(c *gin.Context) {
+	var request schemas.RefreshTokenRequest
+
+	if !apierrors.BindJSON(c, &request) {
+		return
+	}
+
+	var refreshToken models.RefreshToken
+	result := connection.Db.Where("token_hash = ?", tokens.HashOpaqueToken(request.RefreshToken)).First(&refreshToken)
+
+	if result.Error != nil {
+		apierrors.Respond(c, http.StatusForbidden, "Invalid refresh token")
+		return
+	}
+
+	if refreshToken.RevokedAt != nil {
+		if err := revokeFamily(refreshToken.FamilyID); err != nil {
+			c.Error(err)
+		}
+
+		apierrors.Respond(c, http.StatusForbidden, "Invalid refresh token")
+		return
+	}
+
+	if time.Now().After(refreshToken.ExpiresAt) {
+		apierrors.Respond(c, http.StatusForbidden, "Invalid refresh token")
+		return
+	}
+
+	var user models.User
+
+	if err := connection.Db.First(&user, refreshToken.UserID).Error; err != nil {
+		apierrors.Respond(c, http.StatusForbidden, "Invalid refresh token")
+		return
+	}
+
+	var tokenPair schemas.TokenPair
+
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		// The revoked_at condition makes the rotation atomic: when the same
+		// token is presented twice concurrently only one request wins.
+		rotation := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", refreshToken.ID).
+			Update("revoked_at", time.Now())
+
+		if rotation.Error != nil {
+			return rotation.Error
+		}
+
+		if rotation.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		var err error
+		tokenPair, err = IssueTokens(tx, user, refreshToken.FamilyID)
+
+		return err
+	})
+
+	if errors.Is(err, errRefreshTokenReused) {
+		if err := revokeFamily(refreshToken.FamilyID); err != nil {
+			c.Error(err)
+		}
+
+		apierrors.Respond(c, http.StatusForbidden, "Invalid refresh token")
+		return
+	}
+
+	if err != nil {
+		apierrors.Internal(c, err, "It was not possible to issue a token")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, tokenPair)
+}
+
+// RevokeAllTokens invalidates every access and refresh token of the user,
+// so that all of their sessions have to log in again.
+func RevokeAllTokens(db *gorm.DB, userId uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ?", userId).
+			Update("token_version", gorm.Expr("token_version + 1"))
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userId).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+func Logout(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	userId := uint(claims["id"].(float64))
+	jti := claims["jti"].(string)
+	expiresAt, _ := claims.GetExpirationTime()
+
+	// The body is optional: clients that send their refresh token get it
+	// revoked as well, so that the session can't be resumed.
+	var request schemas.LogoutRequest
+
+	if c.Request.Body != nil {
+		if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+			apierrors.Respond(c, http.StatusBadRequest, "The request body is formatted badly")
+			return
+		}
+	}
+
+	if request.RefreshToken != "" {
+		var refreshToken models.RefreshToken
+		result := connection.Db.
+			Where("token_hash = ? AND user_id = ?", tokens.HashOpaqueToken(request.RefreshToken), userId).
+			First(&refreshToken)
+
+		if result.Error == nil {
+			if err := revokeFamily(refreshToken.FamilyID); err != nil {
+				apierrors.Internal(c, err, "Failed to log out")
+				return
+			}
+		}
+	}
+
+	if err := revocation.Revoke(jti, userId, expiresAt.Time); err != nil {
+		apierrors.Internal(c, err, "Failed to log out")
+		return
+	}
+
+	c.IndentedJSON(http.StatusNoContent, nil)
+}
+
+func LogoutAll(c *gin.Context) {
+	claims, _ := tokens.GetClaims(c)
+
+	userId := uint(claims["id"].(float64))
+
+	if err := RevokeAllTokens(connection.Db, userId); err != nil {
+		apierrors.Internal(c, err, "Failed to log out")
+		return
+	}
+
+	c.IndentedJSON(http.StatusNoContent, nil)
+}
+
+// revokeFamily is called when a refresh token that was already rotated is
+// presented again. Since either the legitimate client or an attacker holds a
+// copy, every token descending from the same login is invalidated.
+func revokeFamily(familyId string) error {
+	return connection.Db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now()).Error
+}
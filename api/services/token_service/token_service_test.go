@@ -0,0 +1,354 @@
+package tokenservice_test
+
+import (
+	"database/sql"
+	"diet-app-backend/api/routes"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/tests"
+	"diet-app-backend/util/tokens"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+)
+
+const email = "test.user@test.com"
+const firstName = "Joe"
+const lastName = "Doe"
+const password = "Str0ng-P@ssw0rd"
+const refreshToken = "refresh-token"
+const familyId = "family-id"
+
+var hashedPassword, _ = hashing.HashPassword(password)
+
+type TestSuite struct {
+	suite.Suite
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+func (suite *TestSuite) SetupTest() {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		suite.T().Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	dialector := mysql.New(mysql.Config{
+		DSN:                       "sqlmock_db_0",
+		DriverName:                "mysql",
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	})
+	connection.Connect(dialector)
+
+	suite.db = db
+	suite.mock = mock
+
+	config.LoadEnv("../../../.")
+}
+
+func (suite *TestSuite) TearDownTest() {
+	suite.db.Close()
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func refreshTokenRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "user_id", "family_id", "token_hash", "expires_at", "revoked_at", "created_at"})
+}
+
+func sendRefreshRequest(body string) *httptest.ResponseRecorder {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/token/refresh", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func (suite *TestSuite) TestRefreshSuccessful() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `refresh_tokens` WHERE token_hash = \\? ORDER BY `refresh_tokens`.`id` LIMIT \\?").
+		WithArgs(tokens.HashOpaqueToken(refreshToken), 1).
+		WillReturnRows(
+			refreshTokenRows().
+				AddRow(1, 1, familyId, tokens.HashOpaqueToken(refreshToken), time.Now().Add(time.Hour), nil, time.Now()),
+		)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(1, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `refresh_tokens` SET `revoked_at`=\\? WHERE id = \\? AND revoked_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO `refresh_tokens`").
+		WithArgs(1, familyId, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	suite.mock.ExpectCommit()
+
+	w := sendRefreshRequest(fmt.Sprintf(`{"refresh_token": "%s"}`, refreshToken))
+
+	var responseBody schemas.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.NotEmpty(suite.T(), responseBody.Token)
+	assert.NotEmpty(suite.T(), responseBody.RefreshToken)
+	assert.NotEqual(suite.T(), refreshToken, responseBody.RefreshToken)
+}
+
+func (suite *TestSuite) TestRefreshRequiresRefreshToken() {
+	w := sendRefreshRequest("{}")
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestRefreshUnknownToken() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `refresh_tokens` WHERE token_hash = \\? ORDER BY `refresh_tokens`.`id` LIMIT \\?").
+		WithArgs(tokens.HashOpaqueToken(refreshToken), 1).
+		WillReturnRows(refreshTokenRows())
+
+	w := sendRefreshRequest(fmt.Sprintf(`{"refresh_token": "%s"}`, refreshToken))
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Invalid refresh token", responseBody.Message)
+}
+
+func (suite *TestSuite) TestRefreshExpiredToken() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `refresh_tokens` WHERE token_hash = \\? ORDER BY `refresh_tokens`.`id` LIMIT \\?").
+		WithArgs(tokens.HashOpaqueToken(refreshToken), 1).
+		WillReturnRows(
+			refreshTokenRows().
+				AddRow(1, 1, familyId, tokens.HashOpaqueToken(refreshToken), time.Now().Add(-time.Hour), nil, time.Now()),
+		)
+
+	w := sendRefreshRequest(fmt.Sprintf(`{"refresh_token": "%s"}`, refreshToken))
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Invalid refresh token", responseBody.Message)
+}
+
+func (suite *TestSuite) TestRefreshReusedTokenRevokesFamily() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `refresh_tokens` WHERE token_hash = \\? ORDER BY `refresh_tokens`.`id` LIMIT \\?").
+		WithArgs(tokens.HashOpaqueToken(refreshToken), 1).
+		WillReturnRows(
+			refreshTokenRows().
+				AddRow(1, 1, familyId, tokens.HashOpaqueToken(refreshToken), time.Now().Add(time.Hour), time.Now(), time.Now()),
+		)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `refresh_tokens` SET `revoked_at`=\\? WHERE family_id = \\? AND revoked_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), familyId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	w := sendRefreshRequest(fmt.Sprintf(`{"refresh_token": "%s"}`, refreshToken))
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Invalid refresh token", responseBody.Message)
+}
+
+func (suite *TestSuite) TestExpiredAccessTokenIsRejected() {
+	config.AppConfig.JwtAccessTokenTtl = -time.Minute
+	token, _ := models.User{ID: 1}.IssueToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) expectAuthentication(tokenVersion uint) {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "token_version"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, tokenVersion),
+		)
+}
+
+func (suite *TestSuite) getToken() string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) TestLogoutSuccessful() {
+	token := suite.getToken()
+
+	suite.expectAuthentication(0)
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `revoked_tokens`").
+		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	suite.expectAuthentication(0)
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `revoked_tokens` WHERE jti = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	router := routes.SetupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 204, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/user", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) TestLogoutRevokesRefreshToken() {
+	token := suite.getToken()
+
+	suite.expectAuthentication(0)
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `refresh_tokens` WHERE token_hash = \\? AND user_id = \\? ORDER BY `refresh_tokens`.`id` LIMIT \\?").
+		WithArgs(tokens.HashOpaqueToken(refreshToken), 1, 1).
+		WillReturnRows(
+			refreshTokenRows().
+				AddRow(1, 1, familyId, tokens.HashOpaqueToken(refreshToken), time.Now().Add(time.Hour), nil, time.Now()),
+		)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `refresh_tokens` SET `revoked_at`=\\? WHERE family_id = \\? AND revoked_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), familyId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `revoked_tokens`").
+		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/logout", strings.NewReader(fmt.Sprintf(`{"refresh_token": "%s"}`, refreshToken)))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 204, w.Code)
+}
+
+func (suite *TestSuite) TestLogoutWithoutAuthorization() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/logout", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) TestLogoutAllSuccessful() {
+	token := suite.getToken()
+
+	suite.expectAuthentication(0)
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `token_version`=token_version \\+ 1 WHERE id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^UPDATE `refresh_tokens` SET `revoked_at`=\\? WHERE user_id = \\? AND revoked_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectCommit()
+
+	suite.expectAuthentication(1)
+
+	router := routes.SetupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/logout/all", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 204, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/user", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func TestRunSuite(t *testing.T) {
+	suite.Run(t, new(TestSuite))
+}
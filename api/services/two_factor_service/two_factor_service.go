@@ -0,0 +1,373 @@
+package twofactorservice
+
+import (
+	"crypto/rand"
+	tokenservice "diet-app-backend/api/services/token_service"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/authentication"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/ratelimit"
+	"diet-app-backend/util/tokens"
+	"diet-app-backend/util/totp"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is the number of recovery codes a user gets, each of
+// them working once.
+const recoveryCodeCount = 10
+
+// recoveryCodeAlphabet leaves out the characters that are easily mistaken
+// for others when copied by hand.
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// PostTwoFactorEnrollment generates the secret of a new authenticator. It
+// isn't checked at login until PostTwoFactorVerification confirms it, so an
+// enrollment that was never completed can simply be started over.
+func PostTwoFactorEnrollment(c *gin.Context) {
+	user := authentication.GetUser(c)
+
+	if user.TotpEnabled {
+		apierrors.Respond(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to enroll the authenticator")
+		return
+	}
+
+	if err := connection.Db.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to enroll the authenticator")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, schemas.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(config.AppConfig.TotpIssuer, user.Email, secret),
+	})
+}
+
+// PostTwoFactorVerification enables two-factor authentication once a code of
+// the enrolled authenticator proves that it was set up, and returns the
+// recovery codes.
+func PostTwoFactorVerification(c *gin.Context) {
+	var twoFactorCode schemas.TwoFactorCode
+
+	if !apierrors.BindJSON(c, &twoFactorCode) {
+		return
+	}
+
+	user := authentication.GetUser(c)
+
+	if user.TotpEnabled {
+		apierrors.Respond(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	if user.TotpSecret == "" {
+		apierrors.Respond(c, http.StatusBadRequest, "No authenticator is being enrolled")
+		return
+	}
+
+	step, ok := totp.Match(user.TotpSecret, twoFactorCode.Code, time.Now())
+
+	if !ok {
+		ratelimit.Fail(c)
+		apierrors.Respond(c, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	var codes []string
+
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]any{"totp_enabled": true, "totp_last_step": step}).Error
+
+		if err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+
+		return err
+	})
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	ratelimit.Succeed(c)
+
+	c.IndentedJSON(http.StatusOK, schemas.RecoveryCodes{Codes: codes})
+}
+
+// PostRecoveryCodes replaces the recovery codes of the user with new ones,
+// for when they ran out or were exposed. It takes a code of the
+// authenticator, so that a stolen access token isn't enough.
+func PostRecoveryCodes(c *gin.Context) {
+	var twoFactorCode schemas.TwoFactorCode
+
+	if !apierrors.BindJSON(c, &twoFactorCode) {
+		return
+	}
+
+	user := authentication.GetUser(c)
+
+	if !user.TotpEnabled {
+		apierrors.Respond(c, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	var codes []string
+
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		used, err := useCode(tx, user, twoFactorCode.Code)
+
+		if err != nil || !used {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+
+		return err
+	})
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to generate the recovery codes")
+		return
+	}
+
+	if codes == nil {
+		ratelimit.Fail(c)
+		apierrors.Respond(c, http.StatusForbidden, "Invalid code")
+		return
+	}
+
+	ratelimit.Succeed(c)
+
+	c.IndentedJSON(http.StatusOK, schemas.RecoveryCodes{Codes: codes})
+}
+
+// DeleteTwoFactor disables two-factor authentication, removing the secret of
+// the authenticator and the recovery codes.
+func DeleteTwoFactor(c *gin.Context) {
+	var disableTwoFactor schemas.DisableTwoFactor
+
+	if !apierrors.BindJSON(c, &disableTwoFactor) {
+		return
+	}
+
+	user := authentication.GetUser(c)
+
+	if !hashing.CheckPasswordHash(disableTwoFactor.Password, user.Password) {
+		apierrors.Respond(c, http.StatusForbidden, "Invalid credentials")
+		return
+	}
+
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]any{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.IndentedJSON(http.StatusNoContent, nil)
+}
+
+// PostTwoFactorLogin completes a login started at POST /login, exchanging
+// the challenge token and a code of the authenticator, or a recovery code,
+// for a token pair.
+func PostTwoFactorLogin(c *gin.Context) {
+	var twoFactorLogin schemas.TwoFactorLogin
+
+	if !apierrors.BindJSON(c, &twoFactorLogin) {
+		return
+	}
+
+	user, ok := challengeUser(twoFactorLogin.ChallengeToken)
+
+	if !ok {
+		apierrors.Respond(c, http.StatusForbidden, "The challenge token is invalid or expired")
+		return
+	}
+
+	var tokenPair schemas.TokenPair
+	used := false
+
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		var err error
+
+		if twoFactorLogin.Code != "" {
+			used, err = useCode(tx, user, twoFactorLogin.Code)
+		} else {
+			used, err = useRecoveryCode(tx, user, twoFactorLogin.RecoveryCode)
+		}
+
+		if err != nil || !used {
+			return err
+		}
+
+		tokenPair, err = tokenservice.IssueTokens(tx, user, "")
+
+		return err
+	})
+
+	if err != nil {
+		apierrors.Internal(c, err, "It was not possible to issue a token")
+		return
+	}
+
+	if !used {
+		ratelimit.Fail(c)
+		apierrors.Respond(c, http.StatusForbidden, "Invalid code")
+		return
+	}
+
+	ratelimit.Succeed(c)
+	c.IndentedJSON(http.StatusOK, tokenPair)
+}
+
+var challengeToken = ratelimit.JSONField(func(login schemas.TwoFactorLogin) string { return login.ChallengeToken })
+
+// ChallengeEmail is the ratelimit Key of POST /login/2fa. It returns the
+// email of the challenge token, so that wrong codes count towards the same
+// lockout as wrong passwords.
+func ChallengeEmail(c *gin.Context) string {
+	claims, err := tokens.Parse(challengeToken(c))
+
+	if err != nil || claims["typ"] != models.TokenTypeChallenge {
+		return ""
+	}
+
+	email, _ := claims["email"].(string)
+
+	return strings.ToLower(email)
+}
+
+// challengeUser returns the user a challenge token was issued to, as long as
+// it is still valid. Changing the password or logging out of all sessions
+// invalidates the pending challenges along with the tokens.
+func challengeUser(challengeToken string) (models.User, bool) {
+	claims, err := tokens.Parse(challengeToken)
+
+	if err != nil || claims["typ"] != models.TokenTypeChallenge {
+		return models.User{}, false
+	}
+
+	var user models.User
+
+	if err := connection.Db.First(&user, claims["id"]).Error; err != nil {
+		return models.User{}, false
+	}
+
+	version, ok := claims["ver"].(float64)
+
+	if !ok || uint(version) != user.TokenVersion || !user.TotpEnabled {
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+// useCode checks a code of the authenticator of the user and records its
+// period, returning false when it is wrong or its period was already used.
+func useCode(tx *gorm.DB, user models.User, code string) (bool, error) {
+	step, ok := totp.Match(user.TotpSecret, code, time.Now())
+
+	if !ok || step <= user.TotpLastStep {
+		return false, nil
+	}
+
+	// The condition holds against concurrent logins with the same code
+	result := tx.Model(&user).Where("totp_last_step < ?", step).Update("totp_last_step", step)
+
+	return result.RowsAffected > 0, result.Error
+}
+
+func useRecoveryCode(tx *gorm.DB, user models.User, code string) (bool, error) {
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+
+	return result.RowsAffected > 0, result.Error
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userId uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := []string{}
+	recoveryCodes := []models.RecoveryCode{}
+
+	for range recoveryCodeCount {
+		code, err := generateRecoveryCode()
+
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+		recoveryCodes = append(recoveryCodes, models.RecoveryCode{UserID: userId, CodeHash: hashRecoveryCode(code)})
+	}
+
+	if err := tx.Create(&recoveryCodes).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code of two groups of 5 characters, which
+// is about 49 random bits.
+func generateRecoveryCode() (string, error) {
+	var code strings.Builder
+	bytes := make([]byte, 1)
+
+	for code.Len() < 11 {
+		if code.Len() == 5 {
+			code.WriteByte('-')
+		}
+
+		if _, err := rand.Read(bytes); err != nil {
+			return "", err
+		}
+
+		// Bytes past the last multiple of the alphabet length are skipped,
+		// so that every character is as likely
+		if int(bytes[0]) >= 256-256%len(recoveryCodeAlphabet) {
+			continue
+		}
+
+		code.WriteByte(recoveryCodeAlphabet[int(bytes[0])%len(recoveryCodeAlphabet)])
+	}
+
+	return code.String(), nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes, which users get wrong
+// when typing a code back.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	return tokens.HashOpaqueToken(code)
+}
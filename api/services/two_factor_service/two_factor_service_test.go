@@ -0,0 +1,514 @@
+package twofactorservice_test
+
+import (
+	"database/sql"
+	"diet-app-backend/api/routes"
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"diet-app-backend/schemas"
+	"diet-app-backend/util/config"
+	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/tests"
+	"diet-app-backend/util/tokens"
+	"diet-app-backend/util/totp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+)
+
+const email = "test.user@test.com"
+const firstName = "Joe"
+const lastName = "Doe"
+const password = "Str0ng-P@ssw0rd"
+
+var hashedPassword, _ = hashing.HashPassword(password)
+
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+var userColumns = []string{"id", "email", "first_name", "last_name", "password", "totp_secret", "totp_enabled", "totp_last_step"}
+
+type TestSuite struct {
+	suite.Suite
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+func (suite *TestSuite) SetupTest() {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		suite.T().Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	dialector := mysql.New(mysql.Config{
+		DSN:                       "sqlmock_db_0",
+		DriverName:                "mysql",
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	})
+	connection.Connect(dialector)
+
+	suite.db = db
+	suite.mock = mock
+
+	config.LoadEnv("../../../.")
+}
+
+func (suite *TestSuite) TearDownTest() {
+	suite.db.Close()
+
+	if err := suite.mock.ExpectationsWereMet(); err != nil {
+		suite.T().Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// getToken logs in a user whose current two-factor settings are those given,
+// as loaded by authentication.Authenticate.
+func (suite *TestSuite) getToken(totpSecret string, totpEnabled bool, totpLastStep int64) string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}).
+				AddRow(1, email, firstName, lastName, hashedPassword),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.expectUser(totpSecret, totpEnabled, totpLastStep)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) expectUser(totpSecret string, totpEnabled bool, totpLastStep int64) {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows(userColumns).
+				AddRow(1, email, firstName, lastName, hashedPassword, totpSecret, totpEnabled, totpLastStep),
+		)
+}
+
+func currentCode() string {
+	code, _ := totp.Code(secret, totp.Step(time.Now()))
+	return code
+}
+
+func (suite *TestSuite) TestPostTwoFactorEnrollmentSuccessful() {
+	token := suite.getToken("", false, 0)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `totp_secret`=\\? WHERE `id` = \\?").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/2fa/enroll", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.TwoFactorEnrollment
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Len(suite.T(), responseBody.Secret, 32)
+	assert.True(suite.T(), strings.HasPrefix(responseBody.ProvisioningURI, "otpauth://totp/"))
+	assert.Contains(suite.T(), responseBody.ProvisioningURI, "secret="+responseBody.Secret)
+}
+
+func (suite *TestSuite) TestPostTwoFactorEnrollmentWhenEnabled() {
+	token := suite.getToken(secret, true, 0)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/2fa/enroll", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 409, w.Code)
+	assert.Equal(suite.T(), "Two-factor authentication is already enabled", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostTwoFactorVerificationSuccessful() {
+	token := suite.getToken(secret, false, 0)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `totp_enabled`=\\?,`totp_last_step`=\\? WHERE `id` = \\?").
+		WithArgs(true, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^DELETE FROM `recovery_codes` WHERE user_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectExec("^INSERT INTO `recovery_codes`").
+		WillReturnResult(sqlmock.NewResult(1, 10))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/2fa/verify", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, currentCode())))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.RecoveryCodes
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Len(suite.T(), responseBody.Codes, 10)
+	assert.Regexp(suite.T(), "^[a-z2-9]{5}-[a-z2-9]{5}$", responseBody.Codes[0])
+}
+
+func (suite *TestSuite) TestPostTwoFactorVerificationWrongCode() {
+	token := suite.getToken(secret, false, 0)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/2fa/verify", strings.NewReader(`{"code": "12345"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "Invalid code", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostTwoFactorVerificationLockout() {
+	token := suite.getToken(secret, false, 0)
+
+	router := routes.SetupRouter()
+
+	for i := 0; i <= config.AppConfig.LoginMaxFailures; i++ {
+		if i > 0 {
+			suite.expectUser(secret, false, 0)
+			tests.ExpectTokenNotRevoked(suite.mock)
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/user/2fa/verify", strings.NewReader(`{"code": "000000"}`))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		router.ServeHTTP(w, req)
+
+		if i < config.AppConfig.LoginMaxFailures {
+			assert.Equal(suite.T(), 400, w.Code)
+		} else {
+			assert.Equal(suite.T(), 429, w.Code)
+		}
+	}
+}
+
+func (suite *TestSuite) TestPostTwoFactorVerificationWithoutEnrollment() {
+	token := suite.getToken("", false, 0)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/2fa/verify", strings.NewReader(`{"code": "123456"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "No authenticator is being enrolled", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostRecoveryCodesSuccessful() {
+	token := suite.getToken(secret, true, 0)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `totp_last_step`=\\? WHERE totp_last_step < \\? AND `id` = \\?").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^DELETE FROM `recovery_codes` WHERE user_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	suite.mock.ExpectExec("^INSERT INTO `recovery_codes`").
+		WillReturnResult(sqlmock.NewResult(11, 10))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/user/2fa/recovery-codes", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, currentCode())))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.RecoveryCodes
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Len(suite.T(), responseBody.Codes, 10)
+}
+
+func (suite *TestSuite) TestPostRecoveryCodesLockout() {
+	token := suite.getToken(secret, true, 0)
+
+	router := routes.SetupRouter()
+
+	for i := 0; i <= config.AppConfig.LoginMaxFailures; i++ {
+		if i > 0 {
+			suite.expectUser(secret, true, 0)
+			tests.ExpectTokenNotRevoked(suite.mock)
+		}
+
+		if i < config.AppConfig.LoginMaxFailures {
+			suite.mock.ExpectBegin()
+			suite.mock.ExpectCommit()
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/user/2fa/recovery-codes", strings.NewReader(`{"code": "000000"}`))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		router.ServeHTTP(w, req)
+
+		if i < config.AppConfig.LoginMaxFailures {
+			assert.Equal(suite.T(), 403, w.Code)
+		} else {
+			assert.Equal(suite.T(), 429, w.Code)
+		}
+	}
+}
+
+func (suite *TestSuite) TestDeleteTwoFactorSuccessful() {
+	token := suite.getToken(secret, true, 0)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `totp_enabled`=\\?,`totp_last_step`=\\?,`totp_secret`=\\? WHERE `id` = \\?").
+		WithArgs(false, 0, "", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^DELETE FROM `recovery_codes` WHERE user_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/2fa", strings.NewReader(fmt.Sprintf(`{"password": "%s"}`, password)))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 204, w.Code)
+}
+
+func (suite *TestSuite) TestDeleteTwoFactorWrongPassword() {
+	token := suite.getToken(secret, true, 0)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user/2fa", strings.NewReader(`{"password": "invalid"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Invalid credentials", responseBody.Message)
+}
+
+func (suite *TestSuite) TestLoginWithTwoFactor() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows(userColumns).
+				AddRow(1, email, firstName, lastName, hashedPassword, secret, true, 0),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader(fmt.Sprintf(`{"email": "%s", "password": "%s"}`, email, password)))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody map[string]any
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), true, responseBody["two_factor_required"])
+	assert.NotEmpty(suite.T(), responseBody["challenge_token"])
+	assert.NotContains(suite.T(), responseBody, "token")
+}
+
+func (suite *TestSuite) TestPostTwoFactorLoginWithCode() {
+	challengeToken, _ := models.User{ID: 1, Email: email}.IssueChallengeToken()
+
+	suite.expectUser(secret, true, 0)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `totp_last_step`=\\? WHERE totp_last_step < \\? AND `id` = \\?").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO `refresh_tokens`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"challenge_token": "%s", "code": "%s"}`, challengeToken, currentCode())
+
+	req, _ := http.NewRequest("POST", "/login/2fa", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.NotEmpty(suite.T(), responseBody.Token)
+	assert.NotEmpty(suite.T(), responseBody.RefreshToken)
+}
+
+func (suite *TestSuite) TestPostTwoFactorLoginWithReplayedCode() {
+	challengeToken, _ := models.User{ID: 1, Email: email}.IssueChallengeToken()
+
+	// The current code was already used to log in
+	suite.expectUser(secret, true, totp.Step(time.Now())+totp.Skew)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"challenge_token": "%s", "code": "%s"}`, challengeToken, currentCode())
+
+	req, _ := http.NewRequest("POST", "/login/2fa", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Invalid code", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostTwoFactorLoginWithRecoveryCode() {
+	challengeToken, _ := models.User{ID: 1, Email: email}.IssueChallengeToken()
+
+	suite.expectUser(secret, true, 0)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `recovery_codes` SET `used_at`=\\? WHERE user_id = \\? AND code_hash = \\? AND used_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 1, tokens.HashOpaqueToken("abcde23456")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO `refresh_tokens`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	// Case and dashes don't matter
+	body := fmt.Sprintf(`{"challenge_token": "%s", "recovery_code": "ABCDE-23456"}`, challengeToken)
+
+	req, _ := http.NewRequest("POST", "/login/2fa", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 200, w.Code)
+}
+
+func (suite *TestSuite) TestPostTwoFactorLoginWithUsedRecoveryCode() {
+	challengeToken, _ := models.User{ID: 1, Email: email}.IssueChallengeToken()
+
+	suite.expectUser(secret, true, 0)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `recovery_codes` SET `used_at`=\\?").
+		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"challenge_token": "%s", "recovery_code": "abcde-23456"}`, challengeToken)
+
+	req, _ := http.NewRequest("POST", "/login/2fa", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 403, w.Code)
+}
+
+func (suite *TestSuite) TestPostTwoFactorLoginWithAccessToken() {
+	accessToken, _ := models.User{ID: 1, Email: email}.IssueToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"challenge_token": "%s", "code": "%s"}`, accessToken, currentCode())
+
+	req, _ := http.NewRequest("POST", "/login/2fa", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "The challenge token is invalid or expired", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPostTwoFactorLoginLockout() {
+	challengeToken, _ := models.User{ID: 1, Email: email}.IssueChallengeToken()
+	body := fmt.Sprintf(`{"challenge_token": "%s", "code": "000000"}`, challengeToken)
+
+	router := routes.SetupRouter()
+
+	for i := 0; i < config.AppConfig.LoginMaxFailures; i++ {
+		suite.expectUser(secret, true, 0)
+		suite.mock.ExpectBegin()
+		suite.mock.ExpectCommit()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login/2fa", strings.NewReader(body))
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(suite.T(), 403, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login/2fa", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 429, w.Code)
+}
+
+func TestRunSuite(t *testing.T) {
+	suite.Run(t, new(TestSuite))
+}
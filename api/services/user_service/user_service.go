@@ -1,24 +1,40 @@
 package userservice
 
 import (
+	tokenservice "diet-app-backend/api/services/token_service"
 	"diet-app-backend/database/connection"
 	"diet-app-backend/database/models"
 	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/authentication"
+	"diet-app-backend/util/config"
 	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/mail"
+	"diet-app-backend/util/passwords"
+	"diet-app-backend/util/ratelimit"
+	"diet-app-backend/util/search"
 	"diet-app-backend/util/tokens"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// LoginEmail is the ratelimit Key of POST /login. It reads the email from the
+// body the way Login binds it, so that wrong passwords count towards the
+// lockout of the account Login checks them against.
+var LoginEmail = ratelimit.EmailField(func(credentials schemas.Credentials) string { return credentials.Email })
+
 func Login(c *gin.Context) {
 	var credentials schemas.Credentials
 
-	if error := c.BindJSON(&credentials); error != nil {
-		fmt.Println(error.Error())
-		// TODO return error message
+	if !apierrors.BindJSON(c, &credentials) {
 		return
 	}
 
@@ -26,32 +42,59 @@ func Login(c *gin.Context) {
 	result := connection.Db.First(&user, "email = ?", credentials.Email)
 
 	if result.Error != nil {
-		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "Invalid credentials"})
+		ratelimit.Fail(c)
+		apierrors.Respond(c, http.StatusForbidden, "Invalid credentials")
 		return
 	}
 
 	if isValid := hashing.CheckPasswordHash(credentials.Password, user.Password); !isValid {
-		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "Invalid credentials"})
+		ratelimit.Fail(c)
+		apierrors.Respond(c, http.StatusForbidden, "Invalid credentials")
+		return
+	}
+
+	if user.PendingVerification {
+		apierrors.Respond(c, http.StatusForbidden, "The email of this account is not verified")
 		return
 	}
 
-	tokenString, error := user.IssueToken()
+	// The password alone is not enough, the client must send a code along
+	// with the challenge token to POST /login/2fa
+	if user.TotpEnabled {
+		challengeToken, error := user.IssueChallengeToken()
+
+		if error != nil {
+			apierrors.Internal(c, error, "It was not possible to issue a token")
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, schemas.TwoFactorChallenge{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		})
+		return
+	}
+
+	tokenPair, error := tokenservice.IssueTokens(connection.Db, user, "")
 
 	if error != nil {
-		fmt.Println(error)
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "It was not possible to issue a token"})
+		apierrors.Internal(c, error, "It was not possible to issue a token")
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, gin.H{"token": tokenString})
+	ratelimit.Succeed(c)
+	c.IndentedJSON(http.StatusOK, tokenPair)
 }
 
 func Signup(c *gin.Context) {
 	var user models.User
 
-	if error := c.BindJSON(&user); error != nil {
-		fmt.Println(error.Error())
-		// TODO return error message
+	if !apierrors.BindJSON(c, &user) {
+		return
+	}
+
+	if details := passwords.Default.Check("password", user.Password, user.Email, user.FirstName, user.LastName); len(details) > 0 {
+		apierrors.Invalid(c, details)
 		return
 	}
 
@@ -62,21 +105,156 @@ func Signup(c *gin.Context) {
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
 		Password:  hashed_password,
+		Role:      models.RoleUser,
+		Timezone:  user.Timezone,
+		// The profile is optional at signup
+		Height:        user.Height,
+		BirthDate:     user.BirthDate,
+		Sex:           user.Sex,
+		ActivityLevel: user.ActivityLevel,
+	}
+
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
 	}
 
+	// The account can't be logged in until the link mailed below is followed
+	now := time.Now()
+	user.PendingVerification = true
+	user.VerificationSentAt = &now
+
 	result := connection.Db.Create(&user)
 
 	if error := result.Error; error != nil {
 		if strings.Contains(error.Error(), "Duplicate entry") {
-			c.IndentedJSON(http.StatusConflict, gin.H{"error": "This email is not available"})
+			apierrors.Respond(c, http.StatusConflict, "This email is not available")
 			return
 		}
+
+		apierrors.Internal(c, error, "The account could not be created")
+		return
+	}
+
+	// The account exists nonetheless, a new link can be asked for
+	if error := sendVerificationMail(user); error != nil {
+		c.Error(error)
 	}
 	// Omitting password from the output
 	user.Password = ""
 	c.IndentedJSON(http.StatusCreated, user)
 }
 
+// sendVerificationMail mails the link that verifies the email of the user,
+// in the background since mail servers can be slow to answer.
+func sendVerificationMail(user models.User) error {
+	token, err := user.IssueVerificationToken()
+
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify?token=%s", config.AppConfig.ApiUrl, url.QueryEscape(token))
+
+	message := mail.Message{
+		To:      user.UnverifiedEmail(),
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nFollow this link to verify your email and activate your account:\n\n%s\n\n"+
+				"The link expires in %s.\n",
+			user.FirstName, link, config.AppConfig.VerificationTtl,
+		),
+	}
+
+	go func() {
+		if err := mail.Send(message); err != nil {
+			log.Printf("failed to send a verification mail: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// VerifyEmail activates the account of a verification link, or makes the
+// pending email of the account its email.
+func VerifyEmail(c *gin.Context) {
+	claims, err := tokens.Parse(c.Query("token"))
+
+	if err != nil || claims["typ"] != models.TokenTypeVerification {
+		apierrors.Respond(c, http.StatusBadRequest, "The verification link is invalid or expired")
+		return
+	}
+
+	var user models.User
+
+	if err := connection.Db.First(&user, claims["id"]).Error; err != nil || user.UnverifiedEmail() != claims["email"] {
+		apierrors.Respond(c, http.StatusBadRequest, "The verification link is invalid or expired")
+		return
+	}
+
+	if user.PendingEmail != "" {
+		err := connection.Db.Model(&user).Select("email", "pending_email", "pending_verification").Updates(map[string]any{
+			"email":                user.PendingEmail,
+			"pending_email":        "",
+			"pending_verification": false,
+		}).Error
+
+		if err != nil {
+			// Another account took the email since it was asked for
+			if strings.Contains(err.Error(), "Duplicate entry") {
+				apierrors.Respond(c, http.StatusConflict, "This email is not available")
+				return
+			}
+
+			apierrors.Internal(c, err, "Failed to verify the email")
+			return
+		}
+	} else if user.PendingVerification {
+		if err := connection.Db.Model(&user).Update("pending_verification", false).Error; err != nil {
+			apierrors.Internal(c, err, "Failed to verify the email")
+			return
+		}
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "The email is verified"})
+}
+
+// ResendVerification mails a new verification link, at most once per
+// VERIFICATION_RESEND_INTERVAL for each account. Like the forgotten password
+// flow, the response doesn't tell whether the email belongs to an account.
+func ResendVerification(c *gin.Context) {
+	var resendVerification schemas.ResendVerification
+
+	if !apierrors.BindJSON(c, &resendVerification) {
+		return
+	}
+
+	var user models.User
+	err := connection.Db.Where("email = ? AND pending_verification = ?", resendVerification.Email, true).First(&user).Error
+
+	if err == nil {
+		now := time.Now()
+
+		// The condition makes concurrent requests send a single mail
+		result := connection.Db.Model(&user).
+			Where("verification_sent_at IS NULL OR verification_sent_at < ?", now.Add(-config.AppConfig.VerificationResend)).
+			Update("verification_sent_at", now)
+
+		if result.Error != nil {
+			c.Error(result.Error)
+		} else if result.RowsAffected > 0 {
+			if err := sendVerificationMail(user); err != nil {
+				c.Error(err)
+			}
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(err)
+	}
+
+	c.IndentedJSON(http.StatusAccepted, gin.H{
+		"message": "If this email belongs to an unverified account, a verification link was sent to it",
+	})
+}
+
 func GetUser(c *gin.Context) {
 	claims, _ := tokens.GetClaims(c)
 
@@ -88,3 +266,277 @@ func GetUser(c *gin.Context) {
 	user.Password = ""
 	c.IndentedJSON(http.StatusOK, user)
 }
+
+func PutUser(c *gin.Context) {
+	var updateUser schemas.UpdateUser
+
+	if !apierrors.BindJSON(c, &updateUser) {
+		return
+	}
+
+	user := authentication.GetUser(c)
+	columns := []string{"email", "first_name", "last_name"}
+
+	// A new email has to be verified like the one of the signup, or any
+	// address could be taken over by changing a verified one to it. It
+	// replaces the current one only once verified, so that a typo can't lock
+	// the owner out. Emails only differing in case reach the same mailbox.
+	emailChanged := !strings.EqualFold(updateUser.Email, user.Email)
+
+	if emailChanged {
+		if updateUser.CurrentPassword == "" {
+			apierrors.Invalid(c, []apierrors.FieldError{{
+				Field:   "current_password",
+				Rule:    "required",
+				Message: "current_password is required to change the email",
+			}})
+			return
+		}
+
+		if !hashing.CheckPasswordHash(updateUser.CurrentPassword, user.Password) {
+			apierrors.Respond(c, http.StatusForbidden, "Invalid credentials")
+			return
+		}
+
+		var taken int64
+
+		if err := connection.Db.Model(&models.User{}).Where("email = ?", updateUser.Email).Count(&taken).Error; err != nil {
+			apierrors.Internal(c, err, "Failed to update record")
+			return
+		}
+
+		if taken > 0 {
+			apierrors.Respond(c, http.StatusConflict, "This email is not available")
+			return
+		}
+
+		now := time.Now()
+		user.PendingEmail = updateUser.Email
+		user.VerificationSentAt = &now
+		columns = append(columns, "pending_email", "verification_sent_at")
+	} else {
+		user.Email = updateUser.Email
+	}
+
+	user.FirstName = updateUser.FirstName
+	user.LastName = updateUser.LastName
+
+	err := connection.Db.Model(&user).Select(columns).Updates(&user).Error
+
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			apierrors.Respond(c, http.StatusConflict, "This email is not available")
+			return
+		}
+
+		apierrors.Internal(c, err, "Failed to update record")
+		return
+	}
+
+	if emailChanged {
+		if err := sendVerificationMail(user); err != nil {
+			c.Error(err)
+		}
+	}
+
+	// Omitting password from the output
+	user.Password = ""
+	c.IndentedJSON(http.StatusOK, user)
+}
+
+// PutUserPassword changes the password of the user and logs out all of their
+// sessions, since one of them may belong to whoever learnt the old password.
+// The session of the request gets a new token pair in the response.
+func PutUserPassword(c *gin.Context) {
+	var updatePassword schemas.UpdatePassword
+
+	if !apierrors.BindJSON(c, &updatePassword) {
+		return
+	}
+
+	user := authentication.GetUser(c)
+
+	if !hashing.CheckPasswordHash(updatePassword.CurrentPassword, user.Password) {
+		apierrors.Respond(c, http.StatusForbidden, "Invalid credentials")
+		return
+	}
+
+	if details := passwords.Default.Check("new_password", updatePassword.NewPassword, user.Email, user.FirstName, user.LastName); len(details) > 0 {
+		apierrors.Invalid(c, details)
+		return
+	}
+
+	hashedPassword, err := hashing.HashPassword(updatePassword.NewPassword)
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to update record")
+		return
+	}
+
+	var tokenPair schemas.TokenPair
+
+	err = connection.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+
+		if err := tokenservice.RevokeAllTokens(tx, user.ID); err != nil {
+			return err
+		}
+
+		user.TokenVersion++
+
+		tokenPair, err = tokenservice.IssueTokens(tx, user, "")
+
+		return err
+	})
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to update record")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, tokenPair)
+}
+
+// DeleteUser erases the account of the user along with everything they
+// logged or created, to comply with data erasure requests. Catalog foods are
+// kept, as they don't belong to the user.
+func DeleteUser(c *gin.Context) {
+	var deleteUser schemas.DeleteUser
+
+	if !apierrors.BindJSON(c, &deleteUser) {
+		return
+	}
+
+	user := authentication.GetUser(c)
+
+	if !hashing.CheckPasswordHash(deleteUser.Password, user.Password) {
+		apierrors.Respond(c, http.StatusForbidden, "Invalid credentials")
+		return
+	}
+
+	var foodIds []uint
+
+	err := connection.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Food{}).Where("user_id = ?", user.ID).Pluck("id", &foodIds).Error; err != nil {
+			return err
+		}
+
+		if len(foodIds) > 0 {
+			if err := tx.Where("recipe_id IN ?", foodIds).Delete(&models.RecipeIngredient{}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Where("food_id IN ?", foodIds).Delete(&models.FoodNutrient{}).Error; err != nil {
+				return err
+			}
+		}
+
+		userData := []any{
+			&models.FoodItem{},
+			&models.FoodBarcode{},
+			&models.Food{},
+			&models.CalorieGoal{},
+			&models.CustomMeal{},
+			&models.Measurement{},
+			&models.RefreshToken{},
+			&models.RevokedToken{},
+			&models.PasswordResetToken{},
+			&models.RecoveryCode{},
+		}
+
+		for _, model := range userData {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&user).Error
+	})
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to delete the account")
+		return
+	}
+
+	for _, id := range foodIds {
+		search.Remove(id)
+	}
+
+	c.IndentedJSON(http.StatusNoContent, nil)
+}
+
+func PutUserRole(c *gin.Context) {
+	id := c.Param("id")
+
+	var updateRole schemas.UpdateRole
+
+	if !apierrors.BindJSON(c, &updateRole) {
+		return
+	}
+
+	var user models.User
+	result := connection.Db.First(&user, id)
+
+	if result.Error != nil {
+		apierrors.Respond(c, http.StatusNotFound, "Not found")
+		return
+	}
+
+	user.Role = updateRole.Role
+
+	if err := connection.Db.Model(&user).Update("role", user.Role).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to update record")
+		return
+	}
+
+	// Omitting password from the output
+	user.Password = ""
+	c.IndentedJSON(http.StatusOK, user)
+}
+
+func PutUserTimezone(c *gin.Context) {
+	var updateTimezone schemas.UpdateTimezone
+
+	if !apierrors.BindJSON(c, &updateTimezone) {
+		return
+	}
+
+	user := authentication.GetUser(c)
+	user.Timezone = updateTimezone.Timezone
+
+	if err := connection.Db.Model(&user).Update("timezone", user.Timezone).Error; err != nil {
+		apierrors.Internal(c, err, "Failed to update record")
+		return
+	}
+
+	// Omitting password from the output
+	user.Password = ""
+	c.IndentedJSON(http.StatusOK, user)
+}
+
+func PutUserProfile(c *gin.Context) {
+	var updateProfile schemas.UpdateProfile
+
+	if !apierrors.BindJSON(c, &updateProfile) {
+		return
+	}
+
+	user := authentication.GetUser(c)
+	user.Height = updateProfile.Height
+	user.BirthDate = updateProfile.BirthDate
+	user.Sex = updateProfile.Sex
+	user.ActivityLevel = updateProfile.ActivityLevel
+
+	err := connection.Db.Model(&user).Select("height", "birth_date", "sex", "activity_level").Updates(&user).Error
+
+	if err != nil {
+		apierrors.Internal(c, err, "Failed to update record")
+		return
+	}
+
+	// Omitting password from the output
+	user.Password = ""
+	c.IndentedJSON(http.StatusOK, user)
+}
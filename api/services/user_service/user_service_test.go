@@ -1,14 +1,19 @@
 package userservice_test
 
 import (
+	"crypto/sha1"
 	"database/sql"
 	"diet-app-backend/api/routes"
 	"diet-app-backend/database/connection"
 	"diet-app-backend/database/models"
 	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
 	"diet-app-backend/util/config"
 	"diet-app-backend/util/hashing"
+	"diet-app-backend/util/mail"
+	"diet-app-backend/util/passwords"
 	"diet-app-backend/util/tests"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,6 +21,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -73,6 +79,8 @@ func (suite *TestSuite) TestLoginSuccessful() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	router := routes.SetupRouter()
 	w := httptest.NewRecorder()
 
@@ -90,7 +98,8 @@ func (suite *TestSuite) TestLoginSuccessful() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 200, w.Code)
-	assert.NotNil(suite.T(), responseBody.Token)
+	assert.NotEmpty(suite.T(), responseBody.Token)
+	assert.NotEmpty(suite.T(), responseBody.RefreshToken)
 }
 
 func (suite *TestSuite) TestLoginUserDoesNotExist() {
@@ -117,7 +126,7 @@ func (suite *TestSuite) TestLoginUserDoesNotExist() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 403, w.Code)
-	assert.Equal(suite.T(), "Invalid credentials", responseBody.Error)
+	assert.Equal(suite.T(), "Invalid credentials", responseBody.Message)
 }
 
 func (suite *TestSuite) TestLoginWrongPassword() {
@@ -145,10 +154,187 @@ func (suite *TestSuite) TestLoginWrongPassword() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 403, w.Code)
-	assert.Equal(suite.T(), "Invalid credentials", responseBody.Error)
+	assert.Equal(suite.T(), "Invalid credentials", responseBody.Message)
+}
+
+func (suite *TestSuite) TestLoginLockout() {
+	router := routes.SetupRouter()
+
+	credentials := schemas.Credentials{
+		Email:    email,
+		Password: "invalid",
+	}
+	credentialsJson, _ := json.Marshal(credentials)
+
+	for i := 0; i < config.AppConfig.LoginMaxFailures; i++ {
+		suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+			WithArgs(email, 1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}),
+			)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login", strings.NewReader(string(credentialsJson)))
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(suite.T(), 403, w.Code)
+	}
+
+	// The account is locked out before the password is checked
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader(string(credentialsJson)))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 429, w.Code)
+	assert.Equal(suite.T(), "60", w.Header().Get("Retry-After"))
+	assert.Equal(suite.T(), "Too many attempts, try again later", responseBody.Message)
+}
+
+func (suite *TestSuite) TestLoginLockoutWithDifferentlyCasedFields() {
+	router := routes.SetupRouter()
+
+	// Login binds these fields too, so they count towards the same lockout
+	fields := []string{"Email", "EMAIL", "eMail"}
+
+	for i := 0; i < config.AppConfig.LoginMaxFailures; i++ {
+		suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+			WithArgs(email, 1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}),
+			)
+
+		body := fmt.Sprintf(`{"%s": "%s", "password": "invalid"}`, fields[i%len(fields)], email)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login", strings.NewReader(body))
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(suite.T(), 403, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader(fmt.Sprintf(`{"email": "%s", "password": "invalid"}`, email)))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 429, w.Code)
+}
+
+func (suite *TestSuite) TestLoginRateLimit() {
+	router := routes.SetupRouter()
+
+	for i := 0; i < config.AppConfig.LoginRateLimit; i++ {
+		otherEmail := fmt.Sprintf("user.%d@test.com", i)
+
+		suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+			WithArgs(otherEmail, 1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}),
+			)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login", strings.NewReader(fmt.Sprintf(`{"email": "%s", "password": "invalid"}`, otherEmail)))
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(suite.T(), 403, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader(fmt.Sprintf(`{"email": "%s", "password": "%s"}`, email, password)))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 429, w.Code)
+	assert.Equal(suite.T(), "60", w.Header().Get("Retry-After"))
+}
+
+func (suite *TestSuite) TestLoginRateLimitIgnoresForwardedFor() {
+	router := routes.SetupRouter()
+
+	login := func(email string, password string, forwardedFor string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/login", strings.NewReader(fmt.Sprintf(`{"email": "%s", "password": "%s"}`, email, password)))
+		req.RemoteAddr = "203.0.113.7:41234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	for i := 0; i < config.AppConfig.LoginRateLimit; i++ {
+		otherEmail := fmt.Sprintf("user.%d@test.com", i)
+
+		suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+			WithArgs(otherEmail, 1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password"}),
+			)
+
+		assert.Equal(suite.T(), 403, login(otherEmail, "invalid", fmt.Sprintf("198.51.100.%d", i)).Code)
+	}
+
+	// No proxy is trusted, so a new forwarded address is still the same client
+	w := login(email, password, "198.51.100.250")
+
+	assert.Equal(suite.T(), 429, w.Code)
+}
+
+func (suite *TestSuite) TestSignupWithInvalidFields() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"email": "%s", "password": "%s", "sex": "other"}`, email, password)
+
+	req, _ := http.NewRequest("POST", "/signup", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "validation_failed", responseBody.Code)
+	assert.Equal(suite.T(), []apierrors.FieldError{
+		{Field: "first_name", Rule: "required", Message: "first_name is required"},
+		{Field: "last_name", Rule: "required", Message: "last_name is required"},
+		{Field: "sex", Rule: "oneof", Message: "sex must be one of male, female"},
+	}, responseBody.Details)
+}
+
+func (suite *TestSuite) TestLoginWithMalformedBody() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader(`{"email": `))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "malformed_body", responseBody.Code)
+	assert.Equal(suite.T(), "The request body is not valid JSON", responseBody.Message)
 }
 
 func (suite *TestSuite) TestSignupSuccessful() {
+	mailer := mail.NewMemoryMailer()
+	mail.Default = mailer
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `users`").
+		WithArgs(email, firstName, lastName, sqlmock.AnyArg(), models.RoleUser, 0, "UTC", 0.0, "", "", "", true, "", sqlmock.AnyArg(), nil, "", false, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
 	router := routes.SetupRouter()
 	w := httptest.NewRecorder()
 
@@ -171,6 +357,11 @@ func (suite *TestSuite) TestSignupSuccessful() {
 	assert.Equal(suite.T(), email, responseBody.Email)
 	assert.Equal(suite.T(), firstName, responseBody.FirstName)
 	assert.Equal(suite.T(), lastName, responseBody.LastName)
+	assert.True(suite.T(), responseBody.PendingVerification)
+
+	assert.Eventually(suite.T(), func() bool { return len(mailer.Messages()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(suite.T(), email, mailer.Messages()[0].To)
+	assert.Contains(suite.T(), mailer.Messages()[0].Body, config.AppConfig.ApiUrl+"/verify?token=")
 }
 
 func (suite *TestSuite) TestSignupRequiresEmail() {
@@ -245,10 +436,57 @@ func (suite *TestSuite) TestSignupRequiresPassword() {
 	assert.Equal(suite.T(), 400, w.Code)
 }
 
+func (suite *TestSuite) TestSignupWithWeakPassword() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"email": "%s", "first_name": "%s", "last_name": "%s", "password": "joe123"}`, email, firstName, lastName)
+
+	req, _ := http.NewRequest("POST", "/signup", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "validation_failed", responseBody.Code)
+	assert.Equal(suite.T(), []apierrors.FieldError{
+		{Field: "password", Rule: "min", Message: "password must be at least 10 characters long"},
+		{Field: "password", Rule: "classes", Message: "password must mix at least 3 of lower case letters, upper case letters, digits and symbols"},
+		{Field: "password", Rule: "personal_info", Message: "password must not contain your email or your name"},
+	}, responseBody.Details)
+}
+
+func (suite *TestSuite) TestSignupWithBreachedPassword() {
+	sum := sha1.Sum([]byte("Summer-2024!"))
+	breached, _ := passwords.ParseHashList(strings.NewReader(hex.EncodeToString(sum[:]) + ":1024\n"))
+
+	policy := passwords.Default
+	passwords.Default.Breached = breached
+	defer func() { passwords.Default = policy }()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"email": "%s", "first_name": "%s", "last_name": "%s", "password": "Summer-2024!"}`, email, firstName, lastName)
+
+	req, _ := http.NewRequest("POST", "/signup", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Len(suite.T(), responseBody.Details, 1)
+	assert.Equal(suite.T(), "breached", responseBody.Details[0].Rule)
+}
+
 func (suite *TestSuite) TestSignupRequiresUniqueEmail() {
 	suite.mock.ExpectBegin()
 	suite.mock.ExpectExec("INSERT INTO `users`").
-		WithArgs(email, firstName, lastName, sqlmock.AnyArg()).
+		WithArgs(email, firstName, lastName, sqlmock.AnyArg(), models.RoleUser, 0, "UTC", 0.0, "", "", "", true, "", sqlmock.AnyArg(), nil, "", false, 0).
 		WillReturnError(
 			errors.New("Duplicate entry"),
 		)
@@ -273,7 +511,7 @@ func (suite *TestSuite) TestSignupRequiresUniqueEmail() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 409, w.Code)
-	assert.Equal(suite.T(), "This email is not available", responseBody.Error)
+	assert.Equal(suite.T(), "This email is not available", responseBody.Message)
 }
 
 func (suite *TestSuite) TestGetUser() {
@@ -284,6 +522,8 @@ func (suite *TestSuite) TestGetUser() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -291,6 +531,8 @@ func (suite *TestSuite) TestGetUser() {
 				AddRow(1, email, firstName, lastName, hashedPassword),
 		)
 
+	tests.ExpectTokenNotRevoked(suite.mock)
+
 	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
 		WithArgs(float64(1), 1).
 		WillReturnRows(
@@ -329,7 +571,820 @@ func (suite *TestSuite) TestGetUserWithoutAuthorization() {
 	json.Unmarshal(w.Body.Bytes(), &responseBody)
 
 	assert.Equal(suite.T(), 403, w.Code)
-	assert.Equal(suite.T(), "Authentication failed", responseBody.Error)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) getTokenWithRole(loginRole string, currentRole string) string {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "role"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, loginRole),
+		)
+
+	tests.ExpectRefreshTokenCreation(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "role"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, currentRole),
+		)
+
+	return tests.GetToken(email, password)
+}
+
+func (suite *TestSuite) TestGetUserWithOutdatedRole() {
+	token := suite.getTokenWithRole(models.RoleAdmin, models.RoleUser)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/user", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Authentication failed", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPutUserRoleSuccessful() {
+	token := suite.getTokenWithRole(models.RoleAdmin, models.RoleAdmin)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs("2", 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "role"}).
+				AddRow(2, "dietitian@test.com", firstName, lastName, hashedPassword, models.RoleUser),
+		)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `role`=\\? WHERE `id` = \\?").
+		WithArgs(models.RoleDietitian, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/user/2/role", strings.NewReader(`{"role": "dietitian"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.User
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), uint(2), responseBody.ID)
+	assert.Equal(suite.T(), models.RoleDietitian, responseBody.Role)
+	assert.Empty(suite.T(), responseBody.Password)
+}
+
+func (suite *TestSuite) TestPutUserRoleRequiresValidRole() {
+	token := suite.getTokenWithRole(models.RoleAdmin, models.RoleAdmin)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/user/2/role", strings.NewReader(`{"role": "superuser"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestPutUserRoleRequiresAdmin() {
+	token := suite.getTokenWithRole(models.RoleDietitian, models.RoleDietitian)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/user/2/role", strings.NewReader(`{"role": "admin"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Permission denied", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPutUserTimezoneSuccessful() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `timezone`=\\? WHERE `id` = \\?").
+		WithArgs("Europe/Paris", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/user/timezone", strings.NewReader(`{"timezone": "Europe/Paris"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.User
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), uint(1), responseBody.ID)
+	assert.Equal(suite.T(), "Europe/Paris", responseBody.Timezone)
+	assert.Empty(suite.T(), responseBody.Password)
+}
+
+func (suite *TestSuite) TestPutUserTimezoneRequiresValidTimezone() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/user/timezone", strings.NewReader(`{"timezone": "Mars/Olympus_Mons"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestSignupWithProfile() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO `users`").
+		WithArgs(email, firstName, lastName, sqlmock.AnyArg(), models.RoleUser, 0, "UTC", 180.0, "1994-03-02", models.SexMale, models.ActivityLight, true, "", sqlmock.AnyArg(), nil, "", false, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	userData := models.User{
+		Email:         email,
+		FirstName:     firstName,
+		LastName:      lastName,
+		Password:      password,
+		Height:        180,
+		BirthDate:     "1994-03-02",
+		Sex:           models.SexMale,
+		ActivityLevel: models.ActivityLight,
+	}
+	userDataJson, _ := json.Marshal(userData)
+
+	req, _ := http.NewRequest("POST", "/signup", strings.NewReader(string(userDataJson)))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.User
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 201, w.Code)
+	assert.Equal(suite.T(), 180.0, responseBody.Height)
+	assert.Equal(suite.T(), "1994-03-02", responseBody.BirthDate)
+	assert.Equal(suite.T(), models.SexMale, responseBody.Sex)
+	assert.Equal(suite.T(), models.ActivityLight, responseBody.ActivityLevel)
+}
+
+func (suite *TestSuite) TestSignupRequiresValidBirthDate() {
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	userData := models.User{
+		Email:     email,
+		FirstName: firstName,
+		LastName:  lastName,
+		Password:  password,
+		BirthDate: "02/03/1994",
+	}
+	userDataJson, _ := json.Marshal(userData)
+
+	req, _ := http.NewRequest("POST", "/signup", strings.NewReader(string(userDataJson)))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestPutUserProfileSuccessful() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `height`=\\?,`birth_date`=\\?,`sex`=\\?,`activity_level`=\\? WHERE `id` = \\?").
+		WithArgs(165.0, "1984-11-20", models.SexFemale, models.ActivityModerate, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"height": 165, "birth_date": "1984-11-20", "sex": "female", "activity_level": "moderate"}`
+
+	req, _ := http.NewRequest("PUT", "/user/profile", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.User
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), 165.0, responseBody.Height)
+	assert.Equal(suite.T(), "1984-11-20", responseBody.BirthDate)
+	assert.Equal(suite.T(), models.SexFemale, responseBody.Sex)
+	assert.Equal(suite.T(), models.ActivityModerate, responseBody.ActivityLevel)
+	assert.Empty(suite.T(), responseBody.Password)
+}
+
+func (suite *TestSuite) TestPutUserProfileRequiresValidActivityLevel() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("PUT", "/user/profile", strings.NewReader(`{"activity_level": "couch"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+func (suite *TestSuite) TestPutUserSuccessful() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `email`=\\?,`first_name`=\\?,`last_name`=\\? WHERE `id` = \\?").
+		WithArgs(email, "Jane", lastName, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"email": "%s", "first_name": "Jane", "last_name": "%s"}`, email, lastName)
+
+	req, _ := http.NewRequest("PUT", "/user", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.User
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), "Jane", responseBody.FirstName)
+	assert.False(suite.T(), responseBody.PendingVerification)
+	assert.Empty(suite.T(), responseBody.Password)
+}
+
+func (suite *TestSuite) expectEmailAvailable(email string, count int) {
+	suite.mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `users` WHERE email = \\?").
+		WithArgs(email).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+}
+
+func (suite *TestSuite) TestPutUserWithNewEmail() {
+	mailer := mail.NewMemoryMailer()
+	mail.Default = mailer
+
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.expectEmailAvailable("jane.doe@test.com", 0)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `email`=\\?,`first_name`=\\?,`last_name`=\\?,`pending_email`=\\?,`verification_sent_at`=\\? WHERE `id` = \\?").
+		WithArgs(email, "Jane", lastName, "jane.doe@test.com", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"email": "jane.doe@test.com", "first_name": "Jane", "last_name": "%s", "current_password": "%s"}`, lastName, password)
+
+	req, _ := http.NewRequest("PUT", "/user", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.User
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	// The account keeps its email until the new one is verified
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), email, responseBody.Email)
+	assert.Equal(suite.T(), "jane.doe@test.com", responseBody.PendingEmail)
+	assert.Equal(suite.T(), "Jane", responseBody.FirstName)
+	assert.False(suite.T(), responseBody.PendingVerification)
+	assert.Empty(suite.T(), responseBody.Password)
+
+	// The link verifies the new email
+	assert.Eventually(suite.T(), func() bool { return len(mailer.Messages()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(suite.T(), "jane.doe@test.com", mailer.Messages()[0].To)
+	assert.Contains(suite.T(), mailer.Messages()[0].Body, "/verify?token=")
+}
+
+func (suite *TestSuite) TestPutUserWithEmailInAnotherCase() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `email`=\\?,`first_name`=\\?,`last_name`=\\? WHERE `id` = \\?").
+		WithArgs("Test.User@test.com", firstName, lastName, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	// Neither the password nor a verification is needed for the same mailbox
+	body := fmt.Sprintf(`{"email": "Test.User@test.com", "first_name": "%s", "last_name": "%s"}`, firstName, lastName)
+
+	req, _ := http.NewRequest("PUT", "/user", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody models.User
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), "Test.User@test.com", responseBody.Email)
+	assert.Empty(suite.T(), responseBody.PendingEmail)
+}
+
+func (suite *TestSuite) TestPutUserWithNewEmailRequiresCurrentPassword() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"email": "jane.doe@test.com", "first_name": "Jane", "last_name": "%s"}`, lastName)
+
+	req, _ := http.NewRequest("PUT", "/user", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), []apierrors.FieldError{
+		{Field: "current_password", Rule: "required", Message: "current_password is required to change the email"},
+	}, responseBody.Details)
+}
+
+func (suite *TestSuite) TestPutUserWithNewEmailAndWrongPassword() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"email": "jane.doe@test.com", "first_name": "Jane", "last_name": "%s", "current_password": "wrong"}`, lastName)
+
+	req, _ := http.NewRequest("PUT", "/user", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Invalid credentials", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPutUserRequiresValidEmail() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"email": "jane.doe", "first_name": "Jane", "last_name": "%s"}`, lastName)
+
+	req, _ := http.NewRequest("PUT", "/user", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), []apierrors.FieldError{
+		{Field: "email", Rule: "email", Message: "email must be an email"},
+	}, responseBody.Details)
+}
+
+func (suite *TestSuite) TestPutUserRequiresUniqueEmail() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.expectEmailAvailable("taken@test.com", 1)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"email": "taken@test.com", "first_name": "%s", "last_name": "%s", "current_password": "%s"}`, firstName, lastName, password)
+
+	req, _ := http.NewRequest("PUT", "/user", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 409, w.Code)
+	assert.Equal(suite.T(), "This email is not available", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPutUserPasswordSuccessful() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `password`=\\? WHERE `id` = \\?").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^SAVEPOINT").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectExec("^UPDATE `users` SET `token_version`=token_version \\+ 1 WHERE id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("^UPDATE `refresh_tokens` SET `revoked_at`=\\? WHERE user_id = \\? AND revoked_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("INSERT INTO `refresh_tokens`").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"current_password": "%s", "new_password": "An0ther-P@ssw0rd"}`, password)
+
+	req, _ := http.NewRequest("PUT", "/user/password", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody schemas.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.NotEmpty(suite.T(), responseBody.Token)
+	assert.NotEmpty(suite.T(), responseBody.RefreshToken)
+	assert.NotEqual(suite.T(), token, responseBody.Token)
+}
+
+func (suite *TestSuite) TestPutUserPasswordRequiresCurrentPassword() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := `{"current_password": "wrong", "new_password": "An0ther-P@ssw0rd"}`
+
+	req, _ := http.NewRequest("PUT", "/user/password", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Invalid credentials", responseBody.Message)
+}
+
+func (suite *TestSuite) TestPutUserPasswordWithWeakPassword() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"current_password": "%s", "new_password": "password"}`, password)
+
+	req, _ := http.NewRequest("PUT", "/user/password", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "new_password", responseBody.Details[0].Field)
+	assert.Equal(suite.T(), "min", responseBody.Details[0].Rule)
+}
+
+func (suite *TestSuite) TestDeleteUserSuccessful() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("^SELECT `id` FROM `foods` WHERE user_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12).AddRow(15))
+	suite.mock.ExpectExec("^DELETE FROM `recipe_ingredients` WHERE recipe_id IN \\(\\?,\\?\\)").
+		WithArgs(12, 15).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	suite.mock.ExpectExec("^DELETE FROM `food_nutrients` WHERE food_id IN \\(\\?,\\?\\)").
+		WithArgs(12, 15).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	for _, table := range []string{
+		"food_items", "food_barcodes", "foods", "calorie_goals", "custom_meals",
+		"measurements", "refresh_tokens", "revoked_tokens", "password_reset_tokens", "recovery_codes",
+	} {
+		suite.mock.ExpectExec(fmt.Sprintf("^DELETE FROM `%s` WHERE user_id = \\?", table)).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	suite.mock.ExpectExec("^DELETE FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	body := fmt.Sprintf(`{"password": "%s"}`, password)
+
+	req, _ := http.NewRequest("DELETE", "/user", strings.NewReader(body))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 204, w.Code)
+}
+
+func (suite *TestSuite) TestDeleteUserRequiresPassword() {
+	token := suite.getTokenWithRole(models.RoleUser, models.RoleUser)
+
+	tests.ExpectTokenNotRevoked(suite.mock)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("DELETE", "/user", strings.NewReader(`{"password": "wrong"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "Invalid credentials", responseBody.Message)
+}
+
+func (suite *TestSuite) TestLoginWithUnverifiedEmail() {
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "pending_verification"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, true),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	credentials := schemas.Credentials{
+		Email:    email,
+		Password: password,
+	}
+	credentialsJson, _ := json.Marshal(credentials)
+
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader(string(credentialsJson)))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 403, w.Code)
+	assert.Equal(suite.T(), "The email of this account is not verified", responseBody.Message)
+}
+
+func (suite *TestSuite) TestVerifyEmailSuccessful() {
+	token, _ := models.User{ID: 1, Email: email}.IssueVerificationToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "pending_verification"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, true),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `pending_verification`=\\? WHERE `id` = \\?").
+		WithArgs(false, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/verify?token="+token, nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody map[string]string
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), "The email is verified", responseBody["message"])
+}
+
+func (suite *TestSuite) TestVerifyEmailOfPendingEmail() {
+	token, _ := models.User{ID: 1, Email: email, PendingEmail: "jane.doe@test.com"}.IssueVerificationToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "pending_email"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, "jane.doe@test.com"),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `email`=\\?,`pending_email`=\\?,`pending_verification`=\\? WHERE `id` = \\?").
+		WithArgs("jane.doe@test.com", "", false, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/verify?token="+token, nil)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 200, w.Code)
+}
+
+func (suite *TestSuite) TestVerifyEmailOfPendingEmailTakenSince() {
+	token, _ := models.User{ID: 1, Email: email, PendingEmail: "jane.doe@test.com"}.IssueVerificationToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "pending_email"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, "jane.doe@test.com"),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users`").
+		WillReturnError(errors.New("Duplicate entry"))
+	suite.mock.ExpectRollback()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/verify?token="+token, nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 409, w.Code)
+	assert.Equal(suite.T(), "This email is not available", responseBody.Message)
+}
+
+func (suite *TestSuite) TestVerifyEmailAfterEmailChange() {
+	token, _ := models.User{ID: 1, Email: "old.email@test.com"}.IssueVerificationToken()
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(float64(1), 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "pending_verification"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, true),
+		)
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/verify?token="+token, nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The verification link is invalid or expired", responseBody.Message)
+}
+
+func (suite *TestSuite) TestVerifyEmailWithAccessToken() {
+	token, _ := models.User{ID: 1, Email: email, Role: models.RoleUser}.IssueToken()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("GET", "/verify?token="+token, nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody tests.GenericErrorResponseBody
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Equal(suite.T(), "The verification link is invalid or expired", responseBody.Message)
+}
+
+func (suite *TestSuite) TestResendVerificationSuccessful() {
+	mailer := mail.NewMemoryMailer()
+	mail.Default = mailer
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? AND pending_verification = \\? ORDER BY `users`.`id` LIMIT \\?").
+		WithArgs(email, true, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "pending_verification"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, true),
+		)
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `verification_sent_at`=\\? WHERE \\(verification_sent_at IS NULL OR verification_sent_at < \\?\\) AND `id` = \\?").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/verify/resend", strings.NewReader(fmt.Sprintf(`{"email": "%s"}`, email)))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 202, w.Code)
+	assert.Eventually(suite.T(), func() bool { return len(mailer.Messages()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func (suite *TestSuite) TestResendVerificationIsThrottled() {
+	mailer := mail.NewMemoryMailer()
+	mail.Default = mailer
+
+	suite.mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\? AND pending_verification = \\?").
+		WithArgs(email, true, 1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "password", "pending_verification"}).
+				AddRow(1, email, firstName, lastName, hashedPassword, true),
+		)
+	// A link was sent less than VERIFICATION_RESEND_INTERVAL ago
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("^UPDATE `users` SET `verification_sent_at`=\\?").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectCommit()
+
+	router := routes.SetupRouter()
+	w := httptest.NewRecorder()
+
+	req, _ := http.NewRequest("POST", "/verify/resend", strings.NewReader(fmt.Sprintf(`{"email": "%s"}`, email)))
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 202, w.Code)
+	assert.Never(suite.T(), func() bool { return len(mailer.Messages()) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
 }
 
 func TestRunSuite(t *testing.T) {
@@ -0,0 +1,76 @@
+package main
+
+import (
+	foodservice "diet-app-backend/api/services/food_service"
+	"diet-app-backend/util/foodimport"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runCommand runs a maintenance subcommand instead of the server, returning
+// the exit code of the process.
+func runCommand(name string, args []string) int {
+	switch name {
+	case "import-foods":
+		return importFoods(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %s, the only command is import-foods\n", name)
+		return 2
+	}
+}
+
+// importFoods imports a CSV or JSON dataset into the catalog and prints the
+// report. It fails when any row could not be imported.
+func importFoods(args []string) int {
+	flags := flag.NewFlagSet("import-foods", flag.ContinueOnError)
+	format := flags.String("format", "", "format of the dataset, csv or json, guessed from the file extension by default")
+
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: diet-app-backend import-foods [-format csv|json] file")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+
+	if *format == "" {
+		*format = foodimport.FormatOf(path)
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	reader, err := foodimport.NewReader(file, *format)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	report, err := foodservice.ImportFoods(reader)
+
+	output, _ := json.MarshalIndent(report, "", "    ")
+	fmt.Println(string(output))
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if report.Failed > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -18,6 +18,29 @@ func Connect(dialector gorm.Dialector) {
 	}
 
 	Db.AutoMigrate(&models.User{})
+	// Food names used to be unique across the whole table, they are now
+	// unique per owner so that users can name their custom foods freely.
+	if Db.Migrator().HasConstraint(&models.Food{}, "uni_foods_name") {
+		Db.Migrator().DropConstraint(&models.Food{}, "uni_foods_name")
+	}
 	Db.AutoMigrate(&models.Food{})
+	Db.AutoMigrate(&models.FoodNutrient{})
+	Db.AutoMigrate(&models.FoodBarcode{})
+	Db.AutoMigrate(&models.RecipeIngredient{})
+	Db.AutoMigrate(&models.CustomMeal{})
 	Db.AutoMigrate(&models.FoodItem{})
+	// Entries logged before meals existed are assigned the meal guessed from
+	// their time, the same way models.MealAt does for new entries.
+	Db.Exec(`UPDATE food_items SET meal = CASE
+		WHEN HOUR(timestamp) >= 5 AND HOUR(timestamp) < 11 THEN 'breakfast'
+		WHEN HOUR(timestamp) >= 11 AND HOUR(timestamp) < 15 THEN 'lunch'
+		WHEN HOUR(timestamp) >= 18 AND HOUR(timestamp) < 22 THEN 'dinner'
+		ELSE 'snack' END
+		WHERE meal = ''`)
+	Db.AutoMigrate(&models.CalorieGoal{})
+	Db.AutoMigrate(&models.Measurement{})
+	Db.AutoMigrate(&models.RefreshToken{})
+	Db.AutoMigrate(&models.RevokedToken{})
+	Db.AutoMigrate(&models.PasswordResetToken{})
+	Db.AutoMigrate(&models.RecoveryCode{})
 }
@@ -4,13 +4,21 @@ import (
 	"diet-app-backend/api/routes"
 	"diet-app-backend/database/connection"
 	"diet-app-backend/util/config"
+	"diet-app-backend/util/mail"
+	"diet-app-backend/util/passwords"
+	"diet-app-backend/util/revocation"
+	"diet-app-backend/util/search"
 	"fmt"
+	"os"
+	"time"
 
 	"gorm.io/driver/mysql"
 )
 
 func main() {
 	config.LoadEnv(".")
+	mail.Configure()
+	passwords.Configure()
 
 	dsn := fmt.Sprintf(
 		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
@@ -23,5 +31,12 @@ func main() {
 	dialector := mysql.Open(dsn)
 
 	connection.Connect(dialector)
+
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	revocation.StartPruning(time.Hour)
+	search.StartIndexing(10 * time.Minute)
 	routes.Route()
 }
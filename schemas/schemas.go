@@ -1,6 +1,7 @@
 package schemas
 
 import (
+	"diet-app-backend/database/models"
 	"time"
 )
 
@@ -12,15 +13,273 @@ type Credentials struct {
 type UpdateFoodItem struct {
 	Quantity  uint      `json:"quantity" binding:"required"`
 	Timestamp time.Time `json:"timestamp" binding:"required"`
+	Meal      string    `json:"meal" binding:"max=32"`
 }
 
 type JoinedFoodItem struct {
-	ID        uint      `json:"id"`
-	UserID    uint      `json:"user_id"`
-	FoodID    uint      `json:"food_id"`
-	Name      string    `json:"name"`
-	Calories  int       `json:"calories"`
-	Portion   int       `json:"portion"`
-	Quantity  uint      `json:"quantity"`
-	Timestamp time.Time `json:"timestamp"`
+	ID            uint                  `json:"id"`
+	UserID        uint                  `json:"user_id"`
+	FoodID        uint                  `json:"food_id"`
+	Name          string                `json:"name"`
+	Calories      int                   `json:"calories"`
+	Portion       int                   `json:"portion"`
+	Protein       float64               `json:"protein"`
+	Carbohydrates float64               `json:"carbohydrates"`
+	Fat           float64               `json:"fat"`
+	Fiber         float64               `json:"fiber"`
+	Sugar         float64               `json:"sugar"`
+	Sodium        float64               `json:"sodium"`
+	Quantity      uint                  `json:"quantity"`
+	Timestamp     time.Time             `json:"timestamp"`
+	Meal          string                `json:"meal"`
+	Nutrients     []models.FoodNutrient `json:"nutrients,omitempty" gorm:"-"`
+	Totals        NutrientTotals        `json:"totals" gorm:"-"`
+}
+
+// NutrientTotals are the nutritional values of what was actually eaten in a
+// diary entry, that is the food values scaled by quantity / portion.
+type NutrientTotals struct {
+	Calories      float64               `json:"calories"`
+	Protein       float64               `json:"protein"`
+	Carbohydrates float64               `json:"carbohydrates"`
+	Fat           float64               `json:"fat"`
+	Fiber         float64               `json:"fiber"`
+	Sugar         float64               `json:"sugar"`
+	Sodium        float64               `json:"sodium"`
+	Nutrients     []models.FoodNutrient `json:"nutrients,omitempty"`
+}
+
+func (foodItem *JoinedFoodItem) ComputeTotals() {
+	if foodItem.Portion == 0 {
+		foodItem.Totals = NutrientTotals{}
+		return
+	}
+
+	ratio := float64(foodItem.Quantity) / float64(foodItem.Portion)
+
+	foodItem.Totals = NutrientTotals{
+		Calories:      float64(foodItem.Calories) * ratio,
+		Protein:       foodItem.Protein * ratio,
+		Carbohydrates: foodItem.Carbohydrates * ratio,
+		Fat:           foodItem.Fat * ratio,
+		Fiber:         foodItem.Fiber * ratio,
+		Sugar:         foodItem.Sugar * ratio,
+		Sodium:        foodItem.Sodium * ratio,
+	}
+
+	for _, nutrient := range foodItem.Nutrients {
+		nutrient.Amount *= ratio
+		foodItem.Totals.Nutrients = append(foodItem.Totals.Nutrients, nutrient)
+	}
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type UpdateRole struct {
+	Role string `json:"role" binding:"required,oneof=user dietitian admin"`
+}
+
+// GoalSuggestion is a daily calorie target computed from the profile and the
+// latest weight of a user, in kg, for a weekly weight change Rate in kg.
+// Limited tells that the target was raised to the minimum safe intake.
+type GoalSuggestion struct {
+	Weight   float64 `json:"weight"`
+	Age      int     `json:"age"`
+	BMR      float64 `json:"bmr"`
+	TDEE     float64 `json:"tdee"`
+	Rate     float64 `json:"rate"`
+	Calories uint    `json:"calories"`
+	Limited  bool    `json:"limited"`
+}
+
+// FoodPage is a page of GET /food. Total counts all the matching foods, and
+// NextCursor is null on the last page.
+type FoodPage struct {
+	Items      []models.Food `json:"items"`
+	Total      int64         `json:"total"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+type Recipe struct {
+	Name        string                    `json:"name" binding:"required"`
+	Servings    uint                      `json:"servings" binding:"required,min=1"`
+	Ingredients []models.RecipeIngredient `json:"ingredients" binding:"required,min=1,dive"`
+}
+
+// UpdateUser needs CurrentPassword only when Email changes, so that a stolen
+// token isn't enough to take over the account through a password reset.
+type UpdateUser struct {
+	Email           string `json:"email" binding:"required,email"`
+	FirstName       string `json:"first_name" binding:"required"`
+	LastName        string `json:"last_name" binding:"required"`
+	CurrentPassword string `json:"current_password"`
+}
+
+type UpdatePassword struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// DeleteUser confirms the deletion of an account with its password, so that
+// a stolen token isn't enough to erase it.
+type DeleteUser struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResendVerification struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// TwoFactorEnrollment holds the secret of an authenticator, both as is and
+// as the otpauth URI to show as a QR code.
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RecoveryCodes are shown once, only their hashes are kept.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+type DisableTwoFactor struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// TwoFactorChallenge is returned by POST /login instead of a TokenPair when
+// the account has two-factor authentication enabled.
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
+// TwoFactorLogin completes a login with either a code of the authenticator
+// or a recovery code.
+type TwoFactorLogin struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type UpdateTimezone struct {
+	Timezone string `json:"timezone" binding:"required,timezone"`
+}
+
+// UpdateProfile replaces the profile of a user, which calorie goal
+// suggestions are computed from. Height is in cm, and empty fields clear the
+// profile field.
+type UpdateProfile struct {
+	Height        float64 `json:"height" binding:"omitempty,gt=0,lt=300"`
+	BirthDate     string  `json:"birth_date" binding:"omitempty,datetime=2006-01-02"`
+	Sex           string  `json:"sex" binding:"omitempty,oneof=male female"`
+	ActivityLevel string  `json:"activity_level" binding:"omitempty,oneof=sedentary light moderate active very_active"`
+}
+
+type UpdateCalorieGoal struct {
+	Calories uint `json:"calories" binding:"required,min=1"`
+}
+
+// DailySummary is the calorie balance of a day. Goal and Remaining are null
+// when the user had no goal on that day.
+type DailySummary struct {
+	Date      string        `json:"date"`
+	Consumed  float64       `json:"consumed"`
+	Goal      *uint         `json:"goal"`
+	Remaining *float64      `json:"remaining"`
+	Meals     []MealSummary `json:"meals"`
+}
+
+// MealFoodItems are the diary entries of a meal, as returned by
+// GET /user/food when grouping by meal.
+type MealFoodItems struct {
+	Meal  string           `json:"meal"`
+	Items []JoinedFoodItem `json:"items"`
+}
+
+type MealSummary struct {
+	Meal     string  `json:"meal"`
+	Calories float64 `json:"calories"`
+}
+
+// ImportReport sums up a bulk import. Rows that failed are listed in Errors
+// with the reason why, the others were imported.
+type ImportReport struct {
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Failed  int           `json:"failed"`
+	Errors  []ImportError `json:"errors"`
+}
+
+type ImportError struct {
+	Row   int    `json:"row"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+// DiaryImportReport previews a diary import, or sums it up once committed.
+// Entries are only imported when no row failed, so that the import can be
+// fixed and sent again without creating duplicates.
+type DiaryImportReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Entries  int               `json:"entries"`
+	Matched  []DiaryImportFood `json:"matched_foods"`
+	NewFoods []DiaryImportFood `json:"new_foods"`
+	Errors   []ImportError     `json:"errors"`
+}
+
+// DiaryImportFood is a food the entries of a diary import are logged with.
+// New foods have no ID before the import is committed.
+type DiaryImportFood struct {
+	ID       uint   `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Calories int    `json:"calories"`
+	Portion  int    `json:"portion"`
+	Entries  int    `json:"entries"`
+}
+
+type UpdateMeasurement struct {
+	Value     float64   `json:"value" binding:"required,gt=0"`
+	Unit      string    `json:"unit" binding:"max=8"`
+	Timestamp time.Time `json:"timestamp" binding:"required"`
+}
+
+// MeasurementTrend smooths a kind of measurement over time. Every value is
+// converted to Unit, and Average is the mean of the days within Window days
+// ending on the day of the point.
+type MeasurementTrend struct {
+	Kind   string       `json:"kind"`
+	Unit   string       `json:"unit"`
+	Window int          `json:"window"`
+	Points []TrendPoint `json:"points"`
+}
+
+type TrendPoint struct {
+	Date    string  `json:"date"`
+	Value   float64 `json:"value"`
+	Average float64 `json:"average"`
 }
@@ -0,0 +1,216 @@
+package apierrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// The codes of the errors that aren't told apart by their status alone.
+const (
+	CodeValidation  = "validation_failed"
+	CodeMalformed   = "malformed_body"
+	CodeBadRequest  = "bad_request"
+	CodeForbidden   = "forbidden"
+	CodeNotFound    = "not_found"
+	CodeConflict    = "conflict"
+	CodeRateLimited = "too_many_requests"
+	CodeInternal    = "internal_error"
+)
+
+// Error is the body of every error response. Code is meant for clients to
+// branch on, while Message is meant to be shown to users. Details tells
+// which fields of the request body are invalid.
+type Error struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func init() {
+	// Validation errors name fields the way clients send them
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+			if name == "-" {
+				return ""
+			}
+
+			if name == "" {
+				return field.Name
+			}
+
+			return name
+		})
+	}
+}
+
+// New returns an error with the code of its status.
+func New(status int, message string) Error {
+	return Error{Code: codeOf(status), Message: message}
+}
+
+// Respond writes an error with the code of its status.
+func Respond(c *gin.Context, status int, message string) {
+	c.IndentedJSON(status, New(status, message))
+}
+
+// Internal records err on the context, where the logger of the router
+// picks it up, and responds with a 500 whose message doesn't leak it.
+func Internal(c *gin.Context, err error, message string) {
+	c.Error(err)
+	Respond(c, http.StatusInternalServerError, message)
+}
+
+// Invalid responds with a 400 listing the fields that break rules checked
+// after binding, such as the password policy.
+func Invalid(c *gin.Context, details []FieldError) {
+	c.IndentedJSON(http.StatusBadRequest, Error{
+		Code:    CodeValidation,
+		Message: "The request body is invalid",
+		Details: details,
+	})
+}
+
+// BindJSON binds the JSON body of the request to obj, responding with a 400
+// that lists the invalid fields and returning false when it can't.
+func BindJSON(c *gin.Context, obj any) bool {
+	err := c.ShouldBindJSON(obj)
+
+	if err == nil {
+		return true
+	}
+
+	c.Error(err).SetType(gin.ErrorTypeBind)
+
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &validationErrors):
+		details := []FieldError{}
+
+		for _, fieldError := range validationErrors {
+			field := fieldPath(fieldError)
+
+			details = append(details, FieldError{
+				Field:   field,
+				Rule:    fieldError.Tag(),
+				Message: field + " " + ruleMessage(fieldError),
+			})
+		}
+
+		c.IndentedJSON(http.StatusBadRequest, Error{
+			Code:    CodeValidation,
+			Message: "The request body is invalid",
+			Details: details,
+		})
+	case errors.As(err, &typeError):
+		c.IndentedJSON(http.StatusBadRequest, Error{
+			Code:    CodeValidation,
+			Message: "The request body is invalid",
+			Details: []FieldError{{
+				Field:   typeError.Field,
+				Rule:    "type",
+				Message: fmt.Sprintf("%s must be of type %s", typeError.Field, typeError.Type.Kind()),
+			}},
+		})
+	case errors.Is(err, io.EOF):
+		c.IndentedJSON(http.StatusBadRequest, Error{Code: CodeMalformed, Message: "The request body is missing"})
+	default:
+		c.IndentedJSON(http.StatusBadRequest, Error{Code: CodeMalformed, Message: "The request body is not valid JSON"})
+	}
+
+	return false
+}
+
+func codeOf(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusInternalServerError:
+		return CodeInternal
+	default:
+		return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	}
+}
+
+// fieldPath returns the path of a field from the root of the body, such as
+// ingredients[0].quantity, leaving out the name of the bound struct.
+func fieldPath(fieldError validator.FieldError) string {
+	namespace := fieldError.Namespace()
+
+	if _, path, found := strings.Cut(namespace, "."); found {
+		return path
+	}
+
+	return namespace
+}
+
+func ruleMessage(fieldError validator.FieldError) string {
+	param := fieldError.Param()
+	isText := fieldError.Kind() == reflect.String
+	isList := fieldError.Kind() == reflect.Slice || fieldError.Kind() == reflect.Map
+
+	switch fieldError.Tag() {
+	case "required", "required_without":
+		return "is required"
+	case "min", "gte":
+		if isText {
+			return fmt.Sprintf("must be at least %s characters long", param)
+		}
+
+		if isList {
+			return fmt.Sprintf("must have at least %s items", param)
+		}
+
+		return "must be at least " + param
+	case "max", "lte":
+		if isText {
+			return fmt.Sprintf("must be at most %s characters long", param)
+		}
+
+		if isList {
+			return fmt.Sprintf("must have at most %s items", param)
+		}
+
+		return "must be at most " + param
+	case "gt":
+		return "must be greater than " + param
+	case "lt":
+		return "must be less than " + param
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "datetime":
+		return "must be formatted as " + param
+	case "timezone":
+		return "must be a timezone such as Europe/Paris"
+	case "email":
+		return "must be an email"
+	default:
+		return "is invalid"
+	}
+}
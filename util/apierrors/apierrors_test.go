@@ -0,0 +1,123 @@
+package apierrors_test
+
+import (
+	"diet-app-backend/util/apierrors"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type ingredient struct {
+	FoodID   uint `json:"food_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"gt=0"`
+}
+
+type recipe struct {
+	Name        string       `json:"name" binding:"required,max=8"`
+	Servings    int          `json:"servings" binding:"min=1"`
+	Kind        string       `json:"kind" binding:"omitempty,oneof=soup salad"`
+	Ingredients []ingredient `json:"ingredients" binding:"required,min=1,dive"`
+}
+
+func bind(body string) (*httptest.ResponseRecorder, apierrors.Error, bool) {
+	router := gin.New()
+	bound := false
+
+	router.POST("recipe", func(c *gin.Context) {
+		var r recipe
+		bound = apierrors.BindJSON(c, &r)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/recipe", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	var responseBody apierrors.Error
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	return w, responseBody, bound
+}
+
+func TestBindJSONValid(t *testing.T) {
+	w, _, bound := bind(`{"name": "Soup", "servings": 2, "ingredients": [{"food_id": 1, "quantity": 100}]}`)
+
+	assert.True(t, bound)
+	assert.Equal(t, 200, w.Code)
+}
+
+func TestBindJSONValidationErrors(t *testing.T) {
+	w, responseBody, bound := bind(`{"name": "Pumpkin soup", "kind": "stew", "ingredients": [{"quantity": 0}]}`)
+
+	assert.False(t, bound)
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, apierrors.CodeValidation, responseBody.Code)
+	assert.Equal(t, "The request body is invalid", responseBody.Message)
+	assert.Equal(t, []apierrors.FieldError{
+		{Field: "name", Rule: "max", Message: "name must be at most 8 characters long"},
+		{Field: "servings", Rule: "min", Message: "servings must be at least 1"},
+		{Field: "kind", Rule: "oneof", Message: "kind must be one of soup, salad"},
+		{Field: "ingredients[0].food_id", Rule: "required", Message: "ingredients[0].food_id is required"},
+		{Field: "ingredients[0].quantity", Rule: "gt", Message: "ingredients[0].quantity must be greater than 0"},
+	}, responseBody.Details)
+}
+
+func TestBindJSONTypeError(t *testing.T) {
+	w, responseBody, _ := bind(`{"name": "Soup", "servings": "two"}`)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, apierrors.CodeValidation, responseBody.Code)
+	assert.Len(t, responseBody.Details, 1)
+	assert.Equal(t, "servings", responseBody.Details[0].Field)
+	assert.Equal(t, "type", responseBody.Details[0].Rule)
+}
+
+func TestBindJSONMalformed(t *testing.T) {
+	w, responseBody, _ := bind(`{"name": `)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, apierrors.CodeMalformed, responseBody.Code)
+	assert.Equal(t, "The request body is not valid JSON", responseBody.Message)
+
+	w, responseBody, _ = bind(``)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, apierrors.CodeMalformed, responseBody.Code)
+	assert.Equal(t, "The request body is missing", responseBody.Message)
+}
+
+func TestInternal(t *testing.T) {
+	router := gin.New()
+	var recorded []*gin.Error
+
+	router.GET("fail", func(c *gin.Context) {
+		apierrors.Internal(c, errors.New("connection refused"), "Failed to retrieve the foods")
+		recorded = c.Errors
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/fail", nil)
+
+	router.ServeHTTP(w, req)
+
+	var responseBody apierrors.Error
+	json.Unmarshal(w.Body.Bytes(), &responseBody)
+
+	assert.Equal(t, 500, w.Code)
+	assert.Equal(t, apierrors.CodeInternal, responseBody.Code)
+	assert.Equal(t, "Failed to retrieve the foods", responseBody.Message)
+	assert.NotContains(t, w.Body.String(), "connection refused")
+	assert.Len(t, recorded, 1)
+}
+
+func TestNew(t *testing.T) {
+	assert.Equal(t, apierrors.Error{Code: apierrors.CodeNotFound, Message: "Not found"}, apierrors.New(http.StatusNotFound, "Not found"))
+	assert.Equal(t, "unauthorized", apierrors.New(http.StatusUnauthorized, "").Code)
+	assert.Equal(t, apierrors.CodeRateLimited, apierrors.New(http.StatusTooManyRequests, "").Code)
+}
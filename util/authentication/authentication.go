@@ -3,46 +3,137 @@ package authentication
 import (
 	"diet-app-backend/database/connection"
 	"diet-app-backend/database/models"
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/revocation"
 	"diet-app-backend/util/tokens"
-	"errors"
-	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
+const userKey = "user"
+
+// GetUser returns the user authenticated by Authenticate, saving handlers
+// from loading it a second time.
+func GetUser(c *gin.Context) models.User {
+	user, _ := c.Get(userKey)
+	authenticatedUser, _ := user.(models.User)
+
+	return authenticatedUser
+}
+
+// UserID is a ratelimit Key for the handlers wrapped by Authenticate, which
+// locks out the authenticated account.
+func UserID(c *gin.Context) string {
+	user := GetUser(c)
+
+	if user.ID == 0 {
+		return ""
+	}
+
+	return strconv.FormatUint(uint64(user.ID), 10)
+}
+
 func Authenticate(handler func(c *gin.Context)) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		claims, err := tokens.GetClaims(c)
 
 		if err != nil {
-			fmt.Println(err)
-			c.IndentedJSON(http.StatusForbidden, gin.H{
-				"error": "Authentication failed",
-			})
+			c.Error(err)
+			apierrors.Respond(c, http.StatusForbidden, "Authentication failed")
 			return
 		}
 
 		id, ok := claims["id"]
 
 		if !ok {
-			c.IndentedJSON(http.StatusForbidden, gin.H{
-				"error": "Authentication failed",
-			})
+			apierrors.Respond(c, http.StatusForbidden, "Authentication failed")
 			return
 		}
 
 		var user models.User
 
-		if err := connection.Db.First(&user, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
-			fmt.Println(err)
-			c.IndentedJSON(http.StatusForbidden, gin.H{
-				"error": "Authentication failed",
-			})
+		if err := connection.Db.First(&user, id).Error; err != nil {
+			c.Error(err)
+			apierrors.Respond(c, http.StatusForbidden, "Authentication failed")
 			return
 		}
 
+		// Logging out of all sessions bumps the token version, which
+		// invalidates every token issued before it at once.
+		version, ok := claims["ver"].(float64)
+
+		if !ok || uint(version) != user.TokenVersion {
+			apierrors.Respond(c, http.StatusForbidden, "Authentication failed")
+			return
+		}
+
+		// Tokens issued before a role change are rejected, so that the
+		// new role is picked up when the client refreshes its token.
+		if role, ok := claims["role"].(string); !ok || role != user.Role {
+			apierrors.Respond(c, http.StatusForbidden, "Authentication failed")
+			return
+		}
+
+		jti, ok := claims["jti"].(string)
+
+		if !ok {
+			apierrors.Respond(c, http.StatusForbidden, "Authentication failed")
+			return
+		}
+
+		revoked, err := revocation.IsRevoked(jti)
+
+		if err != nil {
+			c.Error(err)
+			apierrors.Respond(c, http.StatusForbidden, "Authentication failed")
+			return
+		}
+
+		if revoked {
+			apierrors.Respond(c, http.StatusForbidden, "Authentication failed")
+			return
+		}
+
+		c.Set(userKey, user)
+
 		handler(c)
 	}
 }
+
+// OptionalAuthenticate lets anonymous requests through, while requests that
+// carry an Authorization header are authenticated like in Authenticate.
+func OptionalAuthenticate(handler func(c *gin.Context)) func(c *gin.Context) {
+	authenticated := Authenticate(handler)
+
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			handler(c)
+			return
+		}
+
+		authenticated(c)
+	}
+}
+
+// RequireRole restricts a handler to users holding one of the given roles.
+// It is meant to be wrapped by Authenticate, which has already checked that
+// the role claim matches the role currently stored for the user.
+func RequireRole(roles ...string) func(handler func(c *gin.Context)) func(c *gin.Context) {
+	return func(handler func(c *gin.Context)) func(c *gin.Context) {
+		return func(c *gin.Context) {
+			claims, _ := tokens.GetClaims(c)
+
+			role, _ := claims["role"].(string)
+
+			if !slices.Contains(roles, role) {
+				apierrors.Respond(c, http.StatusForbidden, "Permission denied")
+				return
+			}
+
+			handler(c)
+		}
+	}
+}
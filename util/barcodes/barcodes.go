@@ -0,0 +1,54 @@
+package barcodes
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidBarcode = errors.New("the barcode is not a valid EAN-13 or UPC-A code")
+
+// Normalize validates an EAN-13 or UPC-A barcode and returns it as an
+// EAN-13. A UPC-A code is the EAN-13 code starting with 0, so both forms of a
+// barcode printed on a package find the same food.
+func Normalize(code string) (string, error) {
+	code = strings.TrimSpace(code)
+
+	if len(code) == 12 {
+		code = "0" + code
+	}
+
+	if len(code) != 13 {
+		return "", ErrInvalidBarcode
+	}
+
+	for _, digit := range code {
+		if digit < '0' || digit > '9' {
+			return "", ErrInvalidBarcode
+		}
+	}
+
+	if checkDigit(code[:12]) != code[12] {
+		return "", ErrInvalidBarcode
+	}
+
+	return code, nil
+}
+
+// checkDigit computes the GS1 check digit of the given digits: weighting
+// them alternately by 3 and 1 from the right, it is what brings the sum up
+// to a multiple of 10.
+func checkDigit(digits string) byte {
+	sum := 0
+
+	for i := range digits {
+		digit := int(digits[len(digits)-1-i] - '0')
+
+		if i%2 == 0 {
+			digit *= 3
+		}
+
+		sum += digit
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,40 @@
+package barcodes_test
+
+import (
+	"diet-app-backend/util/barcodes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEan13(t *testing.T) {
+	code, err := barcodes.Normalize("4006381333931")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "4006381333931", code)
+}
+
+func TestNormalizeUpcA(t *testing.T) {
+	code, err := barcodes.Normalize("036000291452")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "0036000291452", code)
+}
+
+func TestNormalizeRejectsWrongCheckDigit(t *testing.T) {
+	_, err := barcodes.Normalize("4006381333932")
+
+	assert.ErrorIs(t, err, barcodes.ErrInvalidBarcode)
+}
+
+func TestNormalizeRejectsWrongLength(t *testing.T) {
+	_, err := barcodes.Normalize("96385074")
+
+	assert.ErrorIs(t, err, barcodes.ErrInvalidBarcode)
+}
+
+func TestNormalizeRejectsLetters(t *testing.T) {
+	_, err := barcodes.Normalize("40063813339A1")
+
+	assert.ErrorIs(t, err, barcodes.ErrInvalidBarcode)
+}
@@ -1,18 +1,47 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	JwtPrivateKey string `mapstructure:"JWT_PRIVATE_KEY"`
-	JwtPublicKey  string `mapstructure:"JWT_PUBLIC_KEY"`
-	DbUsername    string `mapstructure:"DB_USERNAME"`
-	DbPassword    string `mapstructure:"DB_PASSWORD"`
-	DbHost        string `mapstructure:"DB_HOST"`
-	DbPort        string `mapstructure:"DB_PORT"`
-	DbDatabase    string `mapstructure:"DB_DATABASE"`
-	FrontEndUrl   string `mapstructure:"FRONT_END_URL"`
+	JwtPrivateKey      string        `mapstructure:"JWT_PRIVATE_KEY"`
+	JwtPublicKey       string        `mapstructure:"JWT_PUBLIC_KEY"`
+	JwtAccessTokenTtl  time.Duration `mapstructure:"JWT_ACCESS_TOKEN_TTL"`
+	JwtRefreshTokenTtl time.Duration `mapstructure:"JWT_REFRESH_TOKEN_TTL"`
+	DbUsername         string        `mapstructure:"DB_USERNAME"`
+	DbPassword         string        `mapstructure:"DB_PASSWORD"`
+	DbHost             string        `mapstructure:"DB_HOST"`
+	DbPort             string        `mapstructure:"DB_PORT"`
+	DbDatabase         string        `mapstructure:"DB_DATABASE"`
+	FrontEndUrl        string        `mapstructure:"FRONT_END_URL"`
+	ApiUrl             string        `mapstructure:"API_URL"`
+	MailDriver         string        `mapstructure:"MAIL_DRIVER"`
+	MailDir            string        `mapstructure:"MAIL_DIR"`
+	MailFrom           string        `mapstructure:"MAIL_FROM"`
+	SmtpHost           string        `mapstructure:"SMTP_HOST"`
+	SmtpPort           string        `mapstructure:"SMTP_PORT"`
+	SmtpUsername       string        `mapstructure:"SMTP_USERNAME"`
+	SmtpPassword       string        `mapstructure:"SMTP_PASSWORD"`
+	PasswordResetTtl   time.Duration `mapstructure:"PASSWORD_RESET_TTL"`
+	ResetResend        time.Duration `mapstructure:"PASSWORD_RESET_RESEND_INTERVAL"`
+	VerificationTtl    time.Duration `mapstructure:"VERIFICATION_TTL"`
+	VerificationResend time.Duration `mapstructure:"VERIFICATION_RESEND_INTERVAL"`
+	TrustedProxies     []string      `mapstructure:"TRUSTED_PROXIES"`
+	RateLimitStore     string        `mapstructure:"RATE_LIMIT_STORE"`
+	LoginRateLimit     int           `mapstructure:"LOGIN_RATE_LIMIT"`
+	LoginRateWindow    time.Duration `mapstructure:"LOGIN_RATE_WINDOW"`
+	LoginMaxFailures   int           `mapstructure:"LOGIN_MAX_FAILURES"`
+	LoginFailureWindow time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginLockout       time.Duration `mapstructure:"LOGIN_LOCKOUT"`
+	LoginMaxLockout    time.Duration `mapstructure:"LOGIN_MAX_LOCKOUT"`
+	TotpIssuer         string        `mapstructure:"TOTP_ISSUER"`
+	TotpChallengeTtl   time.Duration `mapstructure:"TOTP_CHALLENGE_TTL"`
+	PasswordMinLength  int           `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMinClasses int           `mapstructure:"PASSWORD_MIN_CLASSES"`
+	BreachedPasswords  string        `mapstructure:"BREACHED_PASSWORDS"`
 }
 
 var AppConfig Config
@@ -22,6 +51,30 @@ func LoadEnv(configPath string) {
 	viper.SetConfigName("app")
 	viper.SetConfigType("env")
 
+	viper.SetDefault("JWT_ACCESS_TOKEN_TTL", "15m")
+	viper.SetDefault("JWT_REFRESH_TOKEN_TTL", "720h")
+	viper.SetDefault("MAIL_DRIVER", "file")
+	viper.SetDefault("MAIL_DIR", "mail")
+	viper.SetDefault("MAIL_FROM", "no-reply@localhost")
+	viper.SetDefault("SMTP_PORT", "587")
+	viper.SetDefault("PASSWORD_RESET_TTL", "1h")
+	viper.SetDefault("PASSWORD_RESET_RESEND_INTERVAL", "5m")
+	viper.SetDefault("API_URL", "http://localhost:8080")
+	viper.SetDefault("VERIFICATION_TTL", "48h")
+	viper.SetDefault("VERIFICATION_RESEND_INTERVAL", "5m")
+	viper.SetDefault("RATE_LIMIT_STORE", "memory")
+	viper.SetDefault("LOGIN_RATE_LIMIT", 20)
+	viper.SetDefault("LOGIN_RATE_WINDOW", "1m")
+	viper.SetDefault("LOGIN_MAX_FAILURES", 5)
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "24h")
+	viper.SetDefault("LOGIN_LOCKOUT", "1m")
+	viper.SetDefault("LOGIN_MAX_LOCKOUT", "1h")
+	viper.SetDefault("TOTP_ISSUER", "Diet App")
+	viper.SetDefault("TOTP_CHALLENGE_TTL", "5m")
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 10)
+	viper.SetDefault("PASSWORD_MIN_CLASSES", 3)
+	viper.SetDefault("BREACHED_PASSWORDS", "data/breached-passwords.txt")
+
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err != nil {
@@ -0,0 +1,89 @@
+package dates
+
+import (
+	"errors"
+	"time"
+)
+
+// The errors of ParseRange. The bounds of ranges come from the from and to
+// query strings, so their messages are meant to be sent back as is.
+var (
+	ErrBadFrom    = errors.New("The from query string is formatted badly")
+	ErrBadTo      = errors.New("The to query string is formatted badly")
+	ErrEmptyRange = errors.New("The range must end after it starts")
+)
+
+// StartOfDay returns the first instant of the day t falls on in loc.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	year, month, day := t.In(loc).Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// NextDay returns the first instant of the day after the one t falls on, in
+// the location of t. On DST transition days this is 23 or 25 hours after the
+// start of the day rather than 24.
+func NextDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+
+	return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+}
+
+// ParseDay parses a YYYY-MM-DD date into the first instant of that day in
+// loc.
+func ParseDay(value string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(time.DateOnly, value, loc)
+}
+
+// ParseBound parses a bound of a date range, which is either a YYYY-MM-DD
+// date, taken in loc, or an RFC 3339 timestamp. isDay tells which one it was,
+// as a day used as upper bound includes the whole day.
+func ParseBound(value string, loc *time.Location) (bound time.Time, isDay bool, err error) {
+	if bound, err = ParseDay(value, loc); err == nil {
+		return bound, true, nil
+	}
+
+	bound, err = time.Parse(time.RFC3339, value)
+
+	return bound, false, err
+}
+
+// ParseRange parses the bounds of a range with ParseBound, from being
+// included and to excluded, except that a day as to includes the whole day.
+// An empty from is replaced by defaultFrom, and an empty to by the result of
+// defaultTo, which is given the start of the range.
+func ParseRange(from string, to string, loc *time.Location, defaultFrom time.Time, defaultTo func(from time.Time) time.Time) (time.Time, time.Time, error) {
+	start := defaultFrom
+
+	if from != "" {
+		var err error
+
+		if start, _, err = ParseBound(from, loc); err != nil {
+			return start, start, ErrBadFrom
+		}
+	}
+
+	end := time.Time{}
+
+	if to == "" {
+		end = defaultTo(start)
+	} else {
+		bound, isDay, err := ParseBound(to, loc)
+
+		if err != nil {
+			return start, end, ErrBadTo
+		}
+
+		end = bound
+
+		if isDay {
+			end = NextDay(bound)
+		}
+	}
+
+	if !end.After(start) {
+		return start, end, ErrEmptyRange
+	}
+
+	return start, end, nil
+}
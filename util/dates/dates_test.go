@@ -0,0 +1,86 @@
+package dates_test
+
+import (
+	"diet-app-backend/util/dates"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartOfDayUsesTheGivenLocation(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	// 20:00 UTC is already the next day in Tokyo
+	start := dates.StartOfDay(time.Date(2024, time.May, 10, 20, 0, 0, 0, time.UTC), tokyo)
+
+	assert.Equal(t, time.Date(2024, time.May, 11, 0, 0, 0, 0, tokyo), start)
+	assert.Equal(t, time.Date(2024, time.May, 10, 15, 0, 0, 0, time.UTC), start.UTC())
+}
+
+func TestNextDayOnDstTransitions(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	springForward := time.Date(2024, time.March, 10, 0, 0, 0, 0, newYork)
+	fallBack := time.Date(2024, time.November, 3, 0, 0, 0, 0, newYork)
+	regularDay := time.Date(2024, time.May, 10, 0, 0, 0, 0, newYork)
+
+	assert.Equal(t, 23*time.Hour, dates.NextDay(springForward).Sub(springForward))
+	assert.Equal(t, 25*time.Hour, dates.NextDay(fallBack).Sub(fallBack))
+	assert.Equal(t, 24*time.Hour, dates.NextDay(regularDay).Sub(regularDay))
+}
+
+func TestParseBound(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+
+	day, isDay, err := dates.ParseBound("2024-05-10", paris)
+
+	assert.Nil(t, err)
+	assert.True(t, isDay)
+	assert.Equal(t, time.Date(2024, time.May, 10, 0, 0, 0, 0, paris), day)
+
+	timestamp, isDay, err := dates.ParseBound("2024-05-10T12:30:00Z", paris)
+
+	assert.Nil(t, err)
+	assert.False(t, isDay)
+	assert.True(t, time.Date(2024, time.May, 10, 12, 30, 0, 0, time.UTC).Equal(timestamp))
+
+	_, _, err = dates.ParseBound("10/05/2024", paris)
+
+	assert.NotNil(t, err)
+}
+
+func TestParseRange(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+
+	today := time.Date(2024, time.May, 10, 0, 0, 0, 0, paris)
+	dayAfter := func(from time.Time) time.Time { return dates.NextDay(dates.StartOfDay(from, paris)) }
+
+	from, to, err := dates.ParseRange("", "", paris, today, dayAfter)
+
+	assert.Nil(t, err)
+	assert.Equal(t, today, from)
+	assert.Equal(t, time.Date(2024, time.May, 11, 0, 0, 0, 0, paris), to)
+
+	// A day as upper bound includes the whole day
+	from, to, err = dates.ParseRange("2024-05-01", "2024-05-03", paris, today, dayAfter)
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, time.May, 1, 0, 0, 0, 0, paris), from)
+	assert.Equal(t, time.Date(2024, time.May, 4, 0, 0, 0, 0, paris), to)
+
+	// The default upper bound follows the lower bound
+	_, to, err = dates.ParseRange("2024-05-01T12:00:00Z", "", paris, today, dayAfter)
+
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, time.May, 2, 0, 0, 0, 0, paris), to)
+
+	_, _, err = dates.ParseRange("01/05/2024", "", paris, today, dayAfter)
+	assert.Equal(t, dates.ErrBadFrom, err)
+
+	_, _, err = dates.ParseRange("", "tomorrow", paris, today, dayAfter)
+	assert.Equal(t, dates.ErrBadTo, err)
+
+	_, _, err = dates.ParseRange("2024-05-03", "2024-05-01", paris, today, dayAfter)
+	assert.Equal(t, dates.ErrEmptyRange, err)
+}
@@ -0,0 +1,180 @@
+package diaryimport
+
+import (
+	"diet-app-backend/database/models"
+	"diet-app-backend/util/dates"
+	"diet-app-backend/util/numbers"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// dateTimeLayouts are the date formats found in the exports of other
+// trackers, besides the dates and RFC 3339 timestamps of the API.
+var dateTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+// columnAliases maps the header names used by other trackers to the columns
+// of an entry.
+var columnAliases = map[string]string{
+	"date":      "date",
+	"timestamp": "date",
+	"name":      "name",
+	"food":      "name",
+	"amount":    "amount",
+	"quantity":  "amount",
+	"calories":  "calories",
+	"kcal":      "calories",
+	"meal":      "meal",
+}
+
+// Entry is a diary entry read from the export of another tracker. Amount is
+// in grams, and Calories are those of the amount eaten rather than of a
+// portion. Err tells why the entry is invalid.
+type Entry struct {
+	Row       int
+	Timestamp time.Time
+	Name      string
+	Amount    uint
+	Calories  float64
+	Meal      string
+	Err       error
+}
+
+// Read reads a CSV export with a header row holding the date, name, amount
+// and calories columns, plus an optional meal column. Dates without a time
+// are taken as the start of the day in loc, and times without an offset as
+// wall clock times in loc. An error is returned only when the header can't
+// be used or the CSV is unreadable.
+func Read(r io.Reader, loc *time.Location) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("the CSV header could not be read: %w", err)
+	}
+
+	columns := map[string]int{}
+
+	for i, column := range header {
+		if name, ok := columnAliases[strings.ToLower(strings.TrimSpace(column))]; ok {
+			columns[name] = i
+		}
+	}
+
+	for _, column := range []string{"date", "name", "amount", "calories"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("the CSV header has no %s column", column)
+		}
+	}
+
+	entries := []Entry{}
+
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+
+		entry := Entry{Row: row}
+
+		if err != nil {
+			var parseError *csv.ParseError
+
+			if !errors.As(err, &parseError) {
+				return entries, err
+			}
+
+			entry.Err = parseError.Err
+		} else if len(fields) != len(header) {
+			entry.Err = fmt.Errorf("expected %d fields, got %d", len(header), len(fields))
+		} else {
+			entry.Err = parse(&entry, fields, columns, loc)
+		}
+
+		entries = append(entries, entry)
+	}
+}
+
+func parse(entry *Entry, fields []string, columns map[string]int, loc *time.Location) error {
+	field := func(column string) string {
+		index, ok := columns[column]
+
+		if !ok {
+			return ""
+		}
+
+		return strings.TrimSpace(fields[index])
+	}
+
+	timestamp, err := parseDate(field("date"), loc)
+
+	if err != nil {
+		return fmt.Errorf("the date %q is formatted badly", field("date"))
+	}
+
+	entry.Timestamp = timestamp
+	entry.Name = field("name")
+
+	if entry.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(entry.Name) > 191 {
+		return errors.New("name must be at most 191 characters long")
+	}
+
+	amount, err := numbers.ParseFinite(field("amount"))
+
+	if err != nil || amount < 0.5 {
+		return errors.New("amount must be a positive number of grams")
+	}
+
+	entry.Amount = uint(amount + 0.5)
+
+	if entry.Calories, err = numbers.ParseFinite(field("calories")); err != nil || entry.Calories < 0 {
+		return errors.New("calories must be a number of at least 0")
+	}
+
+	entry.Meal = field("meal")
+
+	// Other trackers capitalize the fixed meals
+	if models.IsMeal(strings.ToLower(entry.Meal)) {
+		entry.Meal = strings.ToLower(entry.Meal)
+	}
+
+	if len(entry.Meal) > 32 {
+		return errors.New("meal must be at most 32 characters long")
+	}
+
+	if entry.Meal == "" {
+		entry.Meal = models.MealAt(entry.Timestamp.In(loc))
+	}
+
+	return nil
+}
+
+func parseDate(value string, loc *time.Location) (time.Time, error) {
+	if timestamp, _, err := dates.ParseBound(value, loc); err == nil {
+		return timestamp, nil
+	}
+
+	for _, layout := range dateTimeLayouts {
+		if timestamp, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return timestamp, nil
+		}
+	}
+
+	return time.Time{}, errors.New("unknown date format")
+}
@@ -0,0 +1,85 @@
+package diaryimport_test
+
+import (
+	"diet-app-backend/database/models"
+	"diet-app-backend/util/diaryimport"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRead(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Paris")
+
+	dataset := `Date,Food,Amount,Calories,Meal,Notes
+2024-05-10 08:30,Oatmeal,50,190,Breakfast,
+2024-05-10T20:00:00Z,Pasta,160.4,386,,with friends
+2024-05-11,Protein Bar,60,350,pre-workout,
+`
+
+	entries, err := diaryimport.Read(strings.NewReader(dataset), location)
+
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+
+	oatmeal := entries[0]
+
+	assert.Nil(t, oatmeal.Err)
+	assert.Equal(t, 1, oatmeal.Row)
+	assert.True(t, time.Date(2024, time.May, 10, 8, 30, 0, 0, location).Equal(oatmeal.Timestamp))
+	assert.Equal(t, "Oatmeal", oatmeal.Name)
+	assert.Equal(t, uint(50), oatmeal.Amount)
+	assert.Equal(t, 190.0, oatmeal.Calories)
+	// The fixed meals are matched whatever their case
+	assert.Equal(t, models.MealBreakfast, oatmeal.Meal)
+
+	pasta := entries[1]
+
+	assert.Nil(t, pasta.Err)
+	assert.True(t, time.Date(2024, time.May, 10, 20, 0, 0, 0, time.UTC).Equal(pasta.Timestamp))
+	assert.Equal(t, uint(160), pasta.Amount)
+	// 22:00 in Paris
+	assert.Equal(t, models.MealSnack, pasta.Meal)
+
+	bar := entries[2]
+
+	assert.Nil(t, bar.Err)
+	assert.True(t, time.Date(2024, time.May, 11, 0, 0, 0, 0, location).Equal(bar.Timestamp))
+	assert.Equal(t, "pre-workout", bar.Meal)
+}
+
+func TestReadInvalidRows(t *testing.T) {
+	dataset := `date,name,amount,calories
+10/05/2024,Oatmeal,50,190
+2024-05-10,,50,190
+2024-05-10,Oatmeal,0,190
+2024-05-10,Oatmeal,50,many
+2024-05-10,Oatmeal,50
+2024-05-10,Oatmeal,Inf,190
+2024-05-10,Oatmeal,-Inf,190
+2024-05-10,Oatmeal,50,NaN
+2024-05-10,Oatmeal,NaN,190
+`
+
+	entries, err := diaryimport.Read(strings.NewReader(dataset), time.UTC)
+
+	assert.Nil(t, err)
+	assert.Len(t, entries, 9)
+	assert.EqualError(t, entries[0].Err, `the date "10/05/2024" is formatted badly`)
+	assert.EqualError(t, entries[1].Err, "name is required")
+	assert.EqualError(t, entries[2].Err, "amount must be a positive number of grams")
+	assert.EqualError(t, entries[3].Err, "calories must be a number of at least 0")
+	assert.EqualError(t, entries[4].Err, "expected 4 fields, got 3")
+	assert.EqualError(t, entries[5].Err, "amount must be a positive number of grams")
+	assert.EqualError(t, entries[6].Err, "amount must be a positive number of grams")
+	assert.EqualError(t, entries[7].Err, "calories must be a number of at least 0")
+	assert.EqualError(t, entries[8].Err, "amount must be a positive number of grams")
+}
+
+func TestReadRequiresColumns(t *testing.T) {
+	_, err := diaryimport.Read(strings.NewReader("date,name,calories\n"), time.UTC)
+
+	assert.EqualError(t, err, "the CSV header has no amount column")
+}
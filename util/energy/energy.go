@@ -0,0 +1,47 @@
+package energy
+
+import "diet-app-backend/database/models"
+
+// KcalPerKg is the energy stored in a kilogram of body weight, the usual
+// approximation used to turn a weight change into a calorie balance.
+const KcalPerKg = 7700
+
+// ActivityFactors multiply the BMR into the total daily energy expenditure,
+// from the sedentary lifestyle to a physical job with daily training.
+var ActivityFactors = map[string]float64{
+	models.ActivitySedentary:  1.2,
+	models.ActivityLight:      1.375,
+	models.ActivityModerate:   1.55,
+	models.ActivityActive:     1.725,
+	models.ActivityVeryActive: 1.9,
+}
+
+// MinimumCalories are the daily intakes below which a diet should only be
+// followed under medical supervision.
+var MinimumCalories = map[string]float64{
+	models.SexMale:   1500,
+	models.SexFemale: 1200,
+}
+
+// BMR returns the basal metabolic rate in kcal per day with the Mifflin-St
+// Jeor equation, from a weight in kg and a height in cm.
+func BMR(sex string, weight float64, height float64, age int) float64 {
+	bmr := 10*weight + 6.25*height - 5*float64(age)
+
+	if sex == models.SexMale {
+		return bmr + 5
+	}
+
+	return bmr - 161
+}
+
+// TDEE returns the total daily energy expenditure for an activity level.
+func TDEE(bmr float64, activityLevel string) float64 {
+	return bmr * ActivityFactors[activityLevel]
+}
+
+// Target returns the daily intake that changes the weight by rate kg per
+// week, a negative rate meaning a loss.
+func Target(tdee float64, rate float64) float64 {
+	return tdee + rate*KcalPerKg/7
+}
@@ -0,0 +1,28 @@
+package energy_test
+
+import (
+	"diet-app-backend/database/models"
+	"diet-app-backend/util/energy"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBMR(t *testing.T) {
+	// 10 * 80 + 6.25 * 180 - 5 * 30 + 5
+	assert.InDelta(t, 1780.0, energy.BMR(models.SexMale, 80, 180, 30), 0.001)
+	// 10 * 60 + 6.25 * 165 - 5 * 40 - 161
+	assert.InDelta(t, 1270.25, energy.BMR(models.SexFemale, 60, 165, 40), 0.001)
+}
+
+func TestTDEE(t *testing.T) {
+	assert.InDelta(t, 2136.0, energy.TDEE(1780, models.ActivitySedentary), 0.001)
+	assert.InDelta(t, 2759.0, energy.TDEE(1780, models.ActivityModerate), 0.001)
+}
+
+func TestTarget(t *testing.T) {
+	assert.InDelta(t, 2000.0, energy.Target(2000, 0), 0.001)
+	// Losing half a kilo per week is a 550 kcal deficit per day
+	assert.InDelta(t, 1450.0, energy.Target(2000, -0.5), 0.001)
+	assert.InDelta(t, 2275.0, energy.Target(2000, 0.25), 0.001)
+}
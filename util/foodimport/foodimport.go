@@ -0,0 +1,293 @@
+package foodimport
+
+import (
+	"diet-app-backend/database/models"
+	"diet-app-backend/util/barcodes"
+	"diet-app-backend/util/numbers"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var ErrUnknownFormat = errors.New("the dataset must be a CSV or JSON file")
+
+// Record is a food read from a dataset. Row counts the foods from 1, and Err
+// tells why the food is invalid, in which case it must be skipped.
+type Record struct {
+	Row  int
+	Food models.Food
+	Err  error
+}
+
+// Reader streams the foods of a dataset, so that large datasets are never
+// loaded in memory at once. Next returns io.EOF after the last food, and any
+// other error when the dataset is too malformed to be read further.
+type Reader interface {
+	Next() (Record, error)
+}
+
+// FormatOf guesses the format of a dataset from its file name or content
+// type.
+func FormatOf(nameOrContentType string) string {
+	value := strings.ToLower(nameOrContentType)
+
+	switch {
+	case filepath.Ext(value) == ".csv" || strings.Contains(value, "csv"):
+		return FormatCSV
+	case filepath.Ext(value) == ".json" || strings.Contains(value, "json"):
+		return FormatJSON
+	default:
+		return ""
+	}
+}
+
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSON:
+		return newJSONReader(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// validate applies the binding rules of the API to an imported food, and
+// normalizes its barcodes.
+func validate(food *models.Food) error {
+	if err := binding.Validator.ValidateStruct(food); err != nil {
+		return err
+	}
+
+	for i := range food.Barcodes {
+		code, err := barcodes.Normalize(food.Barcodes[i].Code)
+
+		if err != nil {
+			return fmt.Errorf("the barcode %s is not a valid EAN-13 or UPC-A code", food.Barcodes[i].Code)
+		}
+
+		food.Barcodes[i] = models.FoodBarcode{Code: code}
+	}
+
+	return nil
+}
+
+// nutrientColumn matches the CSV columns of nutrients, such as "Iron (mg)".
+var nutrientColumn = regexp.MustCompile(`^(.+?)\s*\((g|mg|mcg|IU)\)$`)
+
+// csvReader reads a CSV dataset with a header row. The name, calories and
+// portion columns are required, the macros are optional, barcodes are
+// separated by "|" and any column named like "Iron (mg)" is a nutrient.
+// Other columns are ignored, since datasets often carry more data than
+// foods do.
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	header  []string
+	row     int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("the CSV header could not be read: %w", err)
+	}
+
+	columns := map[string]int{}
+
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+
+	for _, column := range []string{"name", "calories", "portion"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("the CSV header has no %s column", column)
+		}
+	}
+
+	return &csvReader{reader: reader, columns: columns, header: header}, nil
+}
+
+func (r *csvReader) Next() (Record, error) {
+	fields, err := r.reader.Read()
+
+	if errors.Is(err, io.EOF) {
+		return Record{}, io.EOF
+	}
+
+	r.row++
+	record := Record{Row: r.row}
+
+	if err != nil {
+		var parseError *csv.ParseError
+
+		if errors.As(err, &parseError) {
+			record.Err = parseError.Err
+			return record, nil
+		}
+
+		return record, err
+	}
+
+	if len(fields) != len(r.header) {
+		record.Err = fmt.Errorf("expected %d fields, got %d", len(r.header), len(fields))
+		return record, nil
+	}
+
+	record.Food, record.Err = r.parse(fields)
+
+	if record.Err == nil {
+		record.Err = validate(&record.Food)
+	}
+
+	return record, nil
+}
+
+func (r *csvReader) parse(fields []string) (models.Food, error) {
+	food := models.Food{Name: r.field(fields, "name")}
+
+	integers := []struct {
+		column string
+		value  *int
+	}{
+		{"calories", &food.Calories},
+		{"portion", &food.Portion},
+	}
+
+	for _, integer := range integers {
+		number, err := strconv.Atoi(r.field(fields, integer.column))
+
+		if err != nil {
+			return food, fmt.Errorf("%s is not a whole number", integer.column)
+		}
+
+		*integer.value = number
+	}
+
+	decimals := []struct {
+		column string
+		value  *float64
+	}{
+		{"protein", &food.Protein},
+		{"carbohydrates", &food.Carbohydrates},
+		{"fat", &food.Fat},
+		{"fiber", &food.Fiber},
+		{"sugar", &food.Sugar},
+		{"sodium", &food.Sodium},
+	}
+
+	for _, decimal := range decimals {
+		if field := r.field(fields, decimal.column); field != "" {
+			number, err := numbers.ParseFinite(field)
+
+			if err != nil {
+				return food, fmt.Errorf("%s is not a number", decimal.column)
+			}
+
+			*decimal.value = number
+		}
+	}
+
+	for _, code := range strings.Split(r.field(fields, "barcodes"), "|") {
+		if code = strings.TrimSpace(code); code != "" {
+			food.Barcodes = append(food.Barcodes, models.FoodBarcode{Code: code})
+		}
+	}
+
+	for i, column := range r.header {
+		match := nutrientColumn.FindStringSubmatch(strings.TrimSpace(column))
+
+		if match == nil || strings.TrimSpace(fields[i]) == "" {
+			continue
+		}
+
+		amount, err := numbers.ParseFinite(strings.TrimSpace(fields[i]))
+
+		if err != nil {
+			return food, fmt.Errorf("%s is not a number", column)
+		}
+
+		food.Nutrients = append(food.Nutrients, models.FoodNutrient{Name: match[1], Amount: amount, Unit: match[2]})
+	}
+
+	return food, nil
+}
+
+func (r *csvReader) field(fields []string, column string) string {
+	index, ok := r.columns[column]
+
+	if !ok {
+		return ""
+	}
+
+	return strings.TrimSpace(fields[index])
+}
+
+// jsonReader reads a JSON array of foods, formatted like the body of
+// POST /food.
+type jsonReader struct {
+	decoder *json.Decoder
+	row     int
+}
+
+func newJSONReader(r io.Reader) (*jsonReader, error) {
+	decoder := json.NewDecoder(r)
+
+	token, err := decoder.Token()
+
+	if err != nil {
+		return nil, fmt.Errorf("the JSON dataset could not be read: %w", err)
+	}
+
+	if delimiter, ok := token.(json.Delim); !ok || delimiter != '[' {
+		return nil, errors.New("the JSON dataset must be an array of foods")
+	}
+
+	return &jsonReader{decoder: decoder}, nil
+}
+
+func (r *jsonReader) Next() (Record, error) {
+	if !r.decoder.More() {
+		return Record{}, io.EOF
+	}
+
+	r.row++
+	record := Record{Row: r.row}
+
+	err := r.decoder.Decode(&record.Food)
+
+	// A value of the wrong type only spoils its own food, but the decoder
+	// can't go on after a syntax error
+	var typeError *json.UnmarshalTypeError
+
+	if errors.As(err, &typeError) {
+		record.Err = fmt.Errorf("%s must be of type %s", typeError.Field, typeError.Type)
+		return record, nil
+	}
+
+	if err != nil {
+		return record, err
+	}
+
+	record.Err = validate(&record.Food)
+
+	return record, nil
+}
@@ -0,0 +1,169 @@
+package foodimport_test
+
+import (
+	"diet-app-backend/util/foodimport"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, reader foodimport.Reader) []foodimport.Record {
+	records := []foodimport.Record{}
+
+	for {
+		record, err := reader.Next()
+
+		if err == io.EOF {
+			return records
+		}
+
+		assert.Nil(t, err)
+
+		records = append(records, record)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	dataset := `Name,Calories,Portion,Protein,Fat,Barcodes,Iron (mg),Source
+Pasta,193,80,5.6,0.9,8076800195057|036000291452,1.3,USDA
+Tomato Sauce,34,100,,,,,USDA
+`
+
+	reader, err := foodimport.NewReader(strings.NewReader(dataset), foodimport.FormatCSV)
+
+	assert.Nil(t, err)
+
+	records := readAll(t, reader)
+
+	assert.Len(t, records, 2)
+
+	pasta := records[0]
+
+	assert.Equal(t, 1, pasta.Row)
+	assert.Nil(t, pasta.Err)
+	assert.Equal(t, "Pasta", pasta.Food.Name)
+	assert.Equal(t, 193, pasta.Food.Calories)
+	assert.Equal(t, 80, pasta.Food.Portion)
+	assert.Equal(t, 5.6, pasta.Food.Protein)
+	assert.Equal(t, 0.9, pasta.Food.Fat)
+	assert.Equal(t, "8076800195057", pasta.Food.Barcodes[0].Code)
+	// Barcodes are normalized to EAN-13
+	assert.Equal(t, "0036000291452", pasta.Food.Barcodes[1].Code)
+	assert.Equal(t, "Iron", pasta.Food.Nutrients[0].Name)
+	assert.Equal(t, 1.3, pasta.Food.Nutrients[0].Amount)
+	assert.Equal(t, "mg", pasta.Food.Nutrients[0].Unit)
+
+	sauce := records[1]
+
+	assert.Equal(t, 2, sauce.Row)
+	assert.Nil(t, sauce.Err)
+	assert.Equal(t, 0.0, sauce.Food.Protein)
+	assert.Empty(t, sauce.Food.Barcodes)
+	assert.Empty(t, sauce.Food.Nutrients)
+}
+
+func TestReadCSVReportsInvalidRows(t *testing.T) {
+	dataset := `name,calories,portion,barcodes
+Pasta,many,80,
+,100,100,
+Ketchup,100,100,123
+Cheese,402
+Rice,130,100,
+`
+
+	reader, err := foodimport.NewReader(strings.NewReader(dataset), foodimport.FormatCSV)
+
+	assert.Nil(t, err)
+
+	records := readAll(t, reader)
+
+	assert.Len(t, records, 5)
+	assert.EqualError(t, records[0].Err, "calories is not a whole number")
+	assert.ErrorContains(t, records[1].Err, "'Name' failed on the 'required' tag")
+	assert.EqualError(t, records[2].Err, "the barcode 123 is not a valid EAN-13 or UPC-A code")
+	assert.EqualError(t, records[3].Err, "expected 4 fields, got 2")
+	assert.Nil(t, records[4].Err)
+	assert.Equal(t, 5, records[4].Row)
+}
+
+func TestReadCSVRejectsNonFiniteNumbers(t *testing.T) {
+	dataset := `name,calories,portion,protein,Iron (mg)
+Pasta,193,80,Inf,1.3
+Rice,130,100,5,NaN
+`
+
+	reader, err := foodimport.NewReader(strings.NewReader(dataset), foodimport.FormatCSV)
+
+	assert.Nil(t, err)
+
+	records := readAll(t, reader)
+
+	assert.Len(t, records, 2)
+	assert.EqualError(t, records[0].Err, "protein is not a number")
+	assert.EqualError(t, records[1].Err, "Iron (mg) is not a number")
+}
+
+func TestReadCSVRequiresColumns(t *testing.T) {
+	_, err := foodimport.NewReader(strings.NewReader("name,calories\n"), foodimport.FormatCSV)
+
+	assert.EqualError(t, err, "the CSV header has no portion column")
+}
+
+func TestReadJSON(t *testing.T) {
+	dataset := `[
+		{"name": "Pasta", "calories": 193, "portion": 80, "nutrients": [{"name": "Iron", "amount": 1.3, "unit": "mg"}]},
+		{"name": "Cheese", "calories": "a lot", "portion": 100},
+		{"name": "Butter", "calories": 717, "portion": 100, "fat": -1},
+		{"name": "Rice", "calories": 130, "portion": 100, "barcodes": [{"code": "036000291452"}]}
+	]`
+
+	reader, err := foodimport.NewReader(strings.NewReader(dataset), foodimport.FormatJSON)
+
+	assert.Nil(t, err)
+
+	records := readAll(t, reader)
+
+	assert.Len(t, records, 4)
+	assert.Nil(t, records[0].Err)
+	assert.Equal(t, "Iron", records[0].Food.Nutrients[0].Name)
+	assert.EqualError(t, records[1].Err, "calories must be of type int")
+	assert.ErrorContains(t, records[2].Err, "'Fat' failed on the 'min' tag")
+	assert.Nil(t, records[3].Err)
+	assert.Equal(t, "0036000291452", records[3].Food.Barcodes[0].Code)
+}
+
+func TestReadJSONStopsOnSyntaxErrors(t *testing.T) {
+	reader, err := foodimport.NewReader(strings.NewReader(`[{"name": "Pasta", "calories": 193, "portion": 80}, {"name": `), foodimport.FormatJSON)
+
+	assert.Nil(t, err)
+
+	record, err := reader.Next()
+
+	assert.Nil(t, err)
+	assert.Nil(t, record.Err)
+
+	_, err = reader.Next()
+
+	assert.NotNil(t, err)
+	assert.NotEqual(t, io.EOF, err)
+}
+
+func TestReadJSONRequiresArray(t *testing.T) {
+	_, err := foodimport.NewReader(strings.NewReader(`{"name": "Pasta"}`), foodimport.FormatJSON)
+
+	assert.EqualError(t, err, "the JSON dataset must be an array of foods")
+}
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, foodimport.FormatCSV, foodimport.FormatOf("usda.CSV"))
+	assert.Equal(t, foodimport.FormatCSV, foodimport.FormatOf("text/csv"))
+	assert.Equal(t, foodimport.FormatJSON, foodimport.FormatOf("foods.json"))
+	assert.Equal(t, foodimport.FormatJSON, foodimport.FormatOf("application/json"))
+	assert.Equal(t, "", foodimport.FormatOf("foods.xlsx"))
+
+	_, err := foodimport.NewReader(strings.NewReader(""), "")
+
+	assert.ErrorIs(t, err, foodimport.ErrUnknownFormat)
+}
@@ -0,0 +1,27 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes every message to an .eml file of Dir, which mail clients
+// can open, instead of sending it.
+type FileMailer struct {
+	Dir   string
+	From  string
+	count atomic.Uint64
+}
+
+func (mailer *FileMailer) Send(message Message) error {
+	if err := os.MkdirAll(mailer.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102-150405"), mailer.count.Add(1))
+
+	return os.WriteFile(filepath.Join(mailer.Dir, name), format(mailer.From, message), 0o644)
+}
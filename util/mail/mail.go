@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"diet-app-backend/util/config"
+	"fmt"
+)
+
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverMemory = "memory"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages to users. The SMTP implementation is meant for
+// production, while the file and memory ones let mails be read during local
+// development and tests without sending anything.
+type Mailer interface {
+	Send(message Message) error
+}
+
+// Default is the mailer used by Send. It keeps mails in memory until
+// Configure picks the one set up in the configuration.
+var Default Mailer = NewMemoryMailer()
+
+func Send(message Message) error {
+	return Default.Send(message)
+}
+
+// Configure sets Default to the mailer of the MAIL_DRIVER setting.
+func Configure() {
+	switch config.AppConfig.MailDriver {
+	case DriverSMTP:
+		Default = &SMTPMailer{
+			Host:     config.AppConfig.SmtpHost,
+			Port:     config.AppConfig.SmtpPort,
+			Username: config.AppConfig.SmtpUsername,
+			Password: config.AppConfig.SmtpPassword,
+			From:     config.AppConfig.MailFrom,
+		}
+	case DriverFile:
+		Default = &FileMailer{Dir: config.AppConfig.MailDir, From: config.AppConfig.MailFrom}
+	case DriverMemory:
+		Default = NewMemoryMailer()
+	default:
+		panic(fmt.Sprintf("unknown mail driver %q", config.AppConfig.MailDriver))
+	}
+}
@@ -0,0 +1,42 @@
+package mail_test
+
+import (
+	"diet-app-backend/util/mail"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer := &mail.FileMailer{Dir: dir, From: "no-reply@test.com"}
+
+	err := mailer.Send(mail.Message{To: "joe@test.com", Subject: "Hello", Body: "First line\nSecond line"})
+
+	assert.Nil(t, err)
+
+	files, _ := os.ReadDir(dir)
+
+	assert.Len(t, files, 1)
+	assert.Equal(t, ".eml", filepath.Ext(files[0].Name()))
+
+	content, _ := os.ReadFile(filepath.Join(dir, files[0].Name()))
+
+	assert.Contains(t, string(content), "From: no-reply@test.com\r\nTo: joe@test.com\r\nSubject: Hello\r\n")
+	assert.Contains(t, string(content), "\r\n\r\nFirst line\r\nSecond line")
+}
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := mail.NewMemoryMailer()
+
+	mailer.Send(mail.Message{To: "joe@test.com"})
+	mailer.Send(mail.Message{To: "jane@test.com"})
+
+	messages := mailer.Messages()
+
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "joe@test.com", messages[0].To)
+	assert.Equal(t, "jane@test.com", messages[1].To)
+}
@@ -0,0 +1,30 @@
+package mail
+
+import "sync"
+
+// MemoryMailer keeps the messages it is given, for tests to read them.
+type MemoryMailer struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (mailer *MemoryMailer) Send(message Message) error {
+	mailer.mutex.Lock()
+	defer mailer.mutex.Unlock()
+
+	mailer.messages = append(mailer.messages, message)
+
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (mailer *MemoryMailer) Messages() []Message {
+	mailer.mutex.Lock()
+	defer mailer.mutex.Unlock()
+
+	return append([]Message{}, mailer.messages...)
+}
@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (mailer *SMTPMailer) Send(message Message) error {
+	var auth smtp.Auth
+
+	if mailer.Username != "" {
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, mailer.Host)
+	}
+
+	return smtp.SendMail(
+		net.JoinHostPort(mailer.Host, mailer.Port),
+		auth,
+		mailer.From,
+		[]string{message.To},
+		format(mailer.From, message),
+	)
+}
+
+// format writes a message in the RFC 5322 format expected by SMTP servers.
+func format(from string, message Message) []byte {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "From: %s\r\n", from)
+	fmt.Fprintf(&builder, "To: %s\r\n", message.To)
+	fmt.Fprintf(&builder, "Subject: %s\r\n", message.Subject)
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	builder.WriteString("\r\n")
+	builder.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(builder.String())
+}
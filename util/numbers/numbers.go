@@ -0,0 +1,27 @@
+package numbers
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// ErrNotFinite is returned by ParseFinite for NaN and infinities.
+var ErrNotFinite = errors.New("not a finite number")
+
+// ParseFinite parses a decimal number like strconv.ParseFloat, except that
+// it rejects NaN and infinities, which ParseFloat accepts but no quantity
+// of food can be.
+func ParseFinite(value string) (float64, error) {
+	number, err := strconv.ParseFloat(value, 64)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, ErrNotFinite
+	}
+
+	return number, nil
+}
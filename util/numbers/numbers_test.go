@@ -0,0 +1,25 @@
+package numbers_test
+
+import (
+	"diet-app-backend/util/numbers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFinite(t *testing.T) {
+	number, err := numbers.ParseFinite("12.5")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 12.5, number)
+
+	for _, value := range []string{"NaN", "nan", "Inf", "+Inf", "-Inf", "infinity"} {
+		_, err := numbers.ParseFinite(value)
+
+		assert.Equal(t, numbers.ErrNotFinite, err, value)
+	}
+
+	_, err = numbers.ParseFinite("many")
+
+	assert.NotNil(t, err)
+}
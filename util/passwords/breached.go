@@ -0,0 +1,100 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// prefixLength is the length of the hash prefixes the list is bucketed by,
+// the same as in the range API of Have I Been Pwned.
+const prefixLength = 5
+
+// HashList is a set of breached passwords, kept as the SHA-1 hashes the
+// breach corpora are published as. Like the range API it mirrors, it stores
+// hash suffixes by prefix, so that the buckets can later be fetched from a
+// shared service instead of being held in memory.
+type HashList struct {
+	buckets map[string]map[string]struct{}
+	size    int
+}
+
+// LoadHashList reads a list of breached passwords from a file. See
+// ParseHashList for its format.
+func LoadHashList(path string) (*HashList, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseHashList(file)
+}
+
+// ParseHashList reads a list of upper or lower case hex SHA-1 hashes, one per
+// line, each optionally followed by a colon and the number of times it was
+// seen as in the downloads of Have I Been Pwned. Blank lines and lines
+// starting with # are skipped.
+func ParseHashList(r io.Reader) (*HashList, error) {
+	list := &HashList{buckets: map[string]map[string]struct{}{}}
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("line %d is not a SHA-1 hash", line)
+		}
+
+		list.add(hash)
+	}
+
+	return list, scanner.Err()
+}
+
+// Contains tells whether the password is in the list.
+func (list *HashList) Contains(password string) bool {
+	prefix, suffix := split(hash(password))
+	_, ok := list.buckets[prefix][suffix]
+
+	return ok
+}
+
+// Len returns the number of hashes in the list.
+func (list *HashList) Len() int {
+	return list.size
+}
+
+func (list *HashList) add(hash string) {
+	prefix, suffix := split(hash)
+
+	if list.buckets[prefix] == nil {
+		list.buckets[prefix] = map[string]struct{}{}
+	}
+
+	if _, ok := list.buckets[prefix][suffix]; !ok {
+		list.buckets[prefix][suffix] = struct{}{}
+		list.size++
+	}
+}
+
+func hash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func split(hash string) (string, string) {
+	return hash[:prefixLength], hash[prefixLength:]
+}
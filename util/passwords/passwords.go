@@ -0,0 +1,142 @@
+package passwords
+
+import (
+	"diet-app-backend/util/apierrors"
+	"diet-app-backend/util/config"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxBytes is the length past which bcrypt refuses to hash a password.
+const MaxBytes = 72
+
+// minPersonalLength keeps short names from rejecting most passwords.
+const minPersonalLength = 3
+
+var personalSeparators = regexp.MustCompile(`[^\pL\pN]+`)
+
+// Policy tells which passwords are strong enough. Classes are lower case
+// letters, upper case letters, digits and symbols, of which a password must
+// mix MinClasses. Breached is left nil to skip that check.
+type Policy struct {
+	MinLength  int
+	MinClasses int
+	Breached   *HashList
+}
+
+// Default is the policy enforced on every new password. It holds until
+// Configure replaces it with the one set up in the configuration.
+var Default = Policy{MinLength: 10, MinClasses: 3}
+
+// Configure sets Default to the policy of the PASSWORD_* settings, loading
+// the list of breached passwords when one is set.
+func Configure() {
+	policy := Policy{
+		MinLength:  config.AppConfig.PasswordMinLength,
+		MinClasses: config.AppConfig.PasswordMinClasses,
+	}
+
+	if path := config.AppConfig.BreachedPasswords; path != "" {
+		list, err := LoadHashList(path)
+
+		if err != nil {
+			panic(fmt.Sprintf("the breached password list could not be loaded: %v", err))
+		}
+
+		policy.Breached = list
+	}
+
+	Default = policy
+}
+
+// Check returns the rules of the policy the password breaks, as errors of
+// the given field. Personal is the information of the user a password must
+// not contain, such as their email and names.
+func (policy Policy) Check(field string, password string, personal ...string) []apierrors.FieldError {
+	fieldErrors := []apierrors.FieldError{}
+
+	fail := func(rule string, message string, args ...any) {
+		fieldErrors = append(fieldErrors, apierrors.FieldError{
+			Field:   field,
+			Rule:    rule,
+			Message: field + " " + fmt.Sprintf(message, args...),
+		})
+	}
+
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		fail("min", "must be at least %d characters long", policy.MinLength)
+	}
+
+	if len(password) > MaxBytes {
+		fail("max", "must be at most %d bytes long", MaxBytes)
+	}
+
+	if classes(password) < policy.MinClasses {
+		fail("classes", "must mix at least %d of lower case letters, upper case letters, digits and symbols", policy.MinClasses)
+	}
+
+	if containsPersonal(password, personal) {
+		fail("personal_info", "must not contain your email or your name")
+	}
+
+	if policy.Breached != nil && policy.Breached.Contains(password) {
+		fail("breached", "appeared in a data breach and is among the first ones attackers try, choose another one")
+	}
+
+	return fieldErrors
+}
+
+func classes(password string) int {
+	var lower, upper, digit, symbol bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+
+	return count
+}
+
+// containsPersonal tells whether the password contains any piece of the
+// personal information, the local part of an email being split on its
+// dots and the like, regardless of case.
+func containsPersonal(password string, personal []string) bool {
+	password = strings.ToLower(password)
+
+	for _, value := range personal {
+		value = strings.ToLower(value)
+
+		if local, _, found := strings.Cut(value, "@"); found {
+			value = local
+		}
+
+		pieces := append(personalSeparators.Split(value, -1), value)
+
+		for _, piece := range pieces {
+			if utf8.RuneCountInString(piece) >= minPersonalLength && strings.Contains(password, piece) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package passwords_test
+
+import (
+	"diet-app-backend/util/passwords"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var policy = passwords.Policy{MinLength: 10, MinClasses: 3}
+
+func rules(password string, personal ...string) []string {
+	rules := []string{}
+
+	for _, fieldError := range policy.Check("password", password, personal...) {
+		rules = append(rules, fieldError.Rule)
+	}
+
+	return rules
+}
+
+func TestCheckStrongPassword(t *testing.T) {
+	assert.Empty(t, policy.Check("password", "Str0ng-P@ssw0rd", "test.user@test.com", "Joe", "Doe"))
+	assert.Empty(t, policy.Check("password", "correct horse battery staple 42"))
+}
+
+func TestCheckLength(t *testing.T) {
+	assert.Equal(t, []string{"min"}, rules("Sh0rt!"))
+	// Length is counted in characters, but bcrypt only hashes 72 bytes
+	assert.Empty(t, rules("Éléphant-42"))
+	assert.Equal(t, []string{"max"}, rules(strings.Repeat("Aa1!", 19)))
+}
+
+func TestCheckClasses(t *testing.T) {
+	assert.Equal(t, []string{"classes"}, rules("onlylowercase"))
+	assert.Equal(t, []string{"classes"}, rules("lowercase123"))
+	assert.Empty(t, rules("Lowercase123"))
+
+	fieldErrors := policy.Check("new_password", "onlylowercase")
+
+	assert.Equal(t, "new_password", fieldErrors[0].Field)
+	assert.Equal(t, "new_password must mix at least 3 of lower case letters, upper case letters, digits and symbols", fieldErrors[0].Message)
+}
+
+func TestCheckPersonalInfo(t *testing.T) {
+	assert.Equal(t, []string{"personal_info"}, rules("Joe-Doe-2024", "joe.doe@test.com"))
+	assert.Equal(t, []string{"personal_info"}, rules("MyNameIsJOSEPHINE1!", "someone@test.com", "Josephine"))
+	// Pieces shorter than 3 characters are ignored
+	assert.Empty(t, rules("Al-Str0ng-P@ss", "al@test.com", "Al"))
+}
+
+func TestCheckBreached(t *testing.T) {
+	breached, err := passwords.ParseHashList(strings.NewReader(
+		"# Common passwords\n" +
+			"\n" +
+			"E38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D:3861493\n" +
+			"9ebe5fcee3f2d9b3e5e8b8fc6b1b4d0a10a9c8e5\n",
+	))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, breached.Len())
+
+	withBreached := passwords.Policy{MinLength: 4, MinClasses: 1, Breached: breached}
+
+	// The first hash is the one of password1
+	assert.True(t, breached.Contains("password1"))
+	assert.False(t, breached.Contains("Password1"))
+	assert.Len(t, withBreached.Check("password", "password1"), 1)
+	assert.Equal(t, "breached", withBreached.Check("password", "password1")[0].Rule)
+	assert.Empty(t, withBreached.Check("password", "password2"))
+}
+
+func TestParseHashListRejectsInvalidLines(t *testing.T) {
+	_, err := passwords.ParseHashList(strings.NewReader("E38AD214943DAAD1D64C102FAEC29DE4AFE9DA3D\npassword1\n"))
+
+	assert.EqualError(t, err, "line 2 is not a SHA-1 hash")
+
+	_, err = passwords.ParseHashList(strings.NewReader("E38AD214943DAAD1D64C\n"))
+
+	assert.EqualError(t, err, "line 1 is not a SHA-1 hash")
+}
+
+func TestLoadHashList(t *testing.T) {
+	list, err := passwords.LoadHashList("../../data/breached-passwords.txt")
+
+	assert.NoError(t, err)
+	assert.True(t, list.Contains("password123"))
+	assert.False(t, list.Contains("Str0ng-P@ssw0rd"))
+
+	_, err = passwords.LoadHashList("missing.txt")
+
+	assert.Error(t, err)
+}
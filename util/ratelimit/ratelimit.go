@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"bytes"
+	"diet-app-backend/util/apierrors"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	failedKey    = "ratelimit_failed"
+	succeededKey = "ratelimit_succeeded"
+)
+
+// Fail tells the Lockout wrapping a handler that the request is a failed
+// attempt, such as a login with a wrong password.
+func Fail(c *gin.Context) {
+	c.Set(failedKey, true)
+}
+
+// Succeed tells the Lockout wrapping a handler that the request completed an
+// attempt, which clears the failures of the account. A step that leaves the
+// attempt incomplete, such as a password check followed by a two-factor
+// code, should call neither.
+func Succeed(c *gin.Context) {
+	c.Set(succeededKey, true)
+}
+
+// PerIP limits the requests of a client address to limit per window.
+// Requests over the limit are answered with 429 until the window ends. The
+// address is the one of gin's ClientIP, so the router must only trust the
+// X-Forwarded-For headers of its own proxies.
+func PerIP(store Store, name string, limit int, window time.Duration) func(handler func(c *gin.Context)) func(c *gin.Context) {
+	return func(handler func(c *gin.Context)) func(c *gin.Context) {
+		return func(c *gin.Context) {
+			count, reset, err := store.Increment(name+":ip:"+c.ClientIP(), window)
+
+			// The limiter fails open, an unavailable store must not lock
+			// everyone out
+			if err != nil {
+				c.Error(err)
+			} else if count > limit {
+				tooManyRequests(c, reset)
+				return
+			}
+
+			handler(c)
+		}
+	}
+}
+
+// Lockout locks an account out once too many failed attempts were made on
+// it, the key of the account being read from the request by Key. The first
+// lockout lasts Duration and every further failure doubles it, up to
+// MaxDuration. Failures are forgotten Window after the first one, or as
+// soon as an attempt succeeds.
+type Lockout struct {
+	Store       Store
+	Name        string
+	Key         func(c *gin.Context) string
+	MaxFailures int
+	Window      time.Duration
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+// Wrap answers with 429 the requests made on a locked account, without
+// running the handler.
+func (lockout Lockout) Wrap(handler func(c *gin.Context)) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		key := lockout.Key(c)
+
+		if key == "" {
+			handler(c)
+			return
+		}
+
+		key = lockout.Name + ":account:" + key
+
+		if blocked, err := lockout.Store.Blocked(key); err != nil {
+			c.Error(err)
+		} else if blocked > 0 {
+			tooManyRequests(c, blocked)
+			return
+		}
+
+		handler(c)
+
+		if c.GetBool(failedKey) {
+			if err := lockout.fail(key); err != nil {
+				c.Error(err)
+			}
+		} else if c.GetBool(succeededKey) {
+			if err := lockout.Store.Reset(key); err != nil {
+				c.Error(err)
+			}
+		}
+	}
+}
+
+func (lockout Lockout) fail(key string) error {
+	failures, _, err := lockout.Store.Increment(key, lockout.Window)
+
+	if err != nil || failures < lockout.MaxFailures {
+		return err
+	}
+
+	return lockout.Store.Block(key, lockout.duration(failures))
+}
+
+// duration returns how long an account is locked after a number of failures
+// that reached MaxFailures.
+func (lockout Lockout) duration(failures int) time.Duration {
+	duration := lockout.Duration
+
+	for i := lockout.MaxFailures; i < failures && duration < lockout.MaxDuration; i++ {
+		duration *= 2
+	}
+
+	return min(duration, lockout.MaxDuration)
+}
+
+// JSONField returns a Key decoding a JSON body into a T and reading the key
+// from it with value. T should be the schema the handler binds, so that the
+// fields are matched the same way, without regard to case, and a key can't
+// be dodged by changing the case of its field. The body is put back for the
+// handler to bind it.
+func JSONField[T any](value func(body T) string) func(c *gin.Context) string {
+	return func(c *gin.Context) string {
+		if c.Request.Body == nil {
+			return ""
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err != nil {
+			return ""
+		}
+
+		var fields T
+
+		if json.Unmarshal(body, &fields) != nil {
+			return ""
+		}
+
+		return strings.TrimSpace(value(fields))
+	}
+}
+
+// EmailField is a JSONField in lower case, since emails are matched without
+// regard to case.
+func EmailField[T any](value func(body T) string) func(c *gin.Context) string {
+	key := JSONField(value)
+
+	return func(c *gin.Context) string {
+		return strings.ToLower(key(c))
+	}
+}
+
+func tooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	apierrors.Respond(c, http.StatusTooManyRequests, "Too many attempts, try again later")
+}
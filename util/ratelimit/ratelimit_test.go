@@ -0,0 +1,168 @@
+package ratelimit_test
+
+import (
+	"diet-app-backend/util/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreIncrement(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+
+	count, reset, _ := store.Increment("key", time.Minute)
+
+	assert.Equal(t, 1, count)
+	assert.InDelta(t, time.Minute, reset, float64(time.Second))
+
+	count, _, _ = store.Increment("key", time.Minute)
+
+	assert.Equal(t, 2, count)
+
+	count, _, _ = store.Increment("other", time.Minute)
+
+	assert.Equal(t, 1, count)
+}
+
+func TestMemoryStoreIncrementAfterExpiry(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+
+	store.Increment("key", 10*time.Millisecond)
+	store.Increment("key", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	count, _, _ := store.Increment("key", time.Minute)
+
+	assert.Equal(t, 1, count)
+}
+
+func TestMemoryStoreBlock(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+
+	blocked, _ := store.Blocked("key")
+
+	assert.Zero(t, blocked)
+
+	store.Block("key", time.Minute)
+	blocked, _ = store.Blocked("key")
+
+	assert.InDelta(t, time.Minute, blocked, float64(time.Second))
+
+	store.Reset("key")
+	blocked, _ = store.Blocked("key")
+
+	assert.Zero(t, blocked)
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func setupRouter(store ratelimit.Store) *gin.Engine {
+	lockout := ratelimit.Lockout{
+		Store:       store,
+		Name:        "login",
+		Key:         ratelimit.EmailField(func(body credentials) string { return body.Email }),
+		MaxFailures: 2,
+		Window:      time.Hour,
+		Duration:    time.Minute,
+		MaxDuration: 3 * time.Minute,
+	}
+	limit := ratelimit.PerIP(store, "login", 10, time.Minute)
+
+	router := gin.New()
+	router.POST("login", limit(lockout.Wrap(func(c *gin.Context) {
+		var body credentials
+		c.BindJSON(&body)
+
+		if body.Password != "right" {
+			ratelimit.Fail(c)
+			c.Status(http.StatusForbidden)
+			return
+		}
+
+		ratelimit.Succeed(c)
+		c.Status(http.StatusOK)
+	})))
+
+	return router
+}
+
+func login(router *gin.Engine, email string, password string) *httptest.ResponseRecorder {
+	return post(router, `{"email": "`+email+`", "password": "`+password+`"}`)
+}
+
+func post(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login", strings.NewReader(body))
+
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestLockoutDoublesAfterEachFailure(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	router := setupRouter(store)
+
+	assert.Equal(t, 403, login(router, "joe@test.com", "wrong").Code)
+	assert.Equal(t, 403, login(router, "Joe@Test.com", "wrong").Code)
+
+	w := login(router, "joe@test.com", "right")
+
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Other accounts are not affected
+	assert.Equal(t, 200, login(router, "jane@test.com", "right").Code)
+
+	// The third failure doubles the lockout, the fourth reaches the maximum
+	for _, lockout := range []time.Duration{2 * time.Minute, 3 * time.Minute} {
+		// Lift the lock to let the next failure through, the counter stays
+		store.Block("login:account:joe@test.com", 0)
+
+		assert.Equal(t, 403, login(router, "joe@test.com", "wrong").Code)
+
+		blocked, _ := store.Blocked("login:account:joe@test.com")
+
+		assert.InDelta(t, lockout, blocked, float64(time.Second))
+	}
+}
+
+func TestLockoutMatchesFieldsLikeTheHandler(t *testing.T) {
+	router := setupRouter(ratelimit.NewMemoryStore())
+
+	// The handler binds the email whatever the case of its field, so the
+	// failures count towards the same account
+	assert.Equal(t, 403, post(router, `{"Email": "joe@test.com", "password": "wrong"}`).Code)
+	assert.Equal(t, 403, post(router, `{"EMAIL": "joe@test.com", "Password": "wrong"}`).Code)
+	assert.Equal(t, 429, login(router, "joe@test.com", "right").Code)
+}
+
+func TestLockoutResetsOnSuccess(t *testing.T) {
+	router := setupRouter(ratelimit.NewMemoryStore())
+
+	assert.Equal(t, 403, login(router, "joe@test.com", "wrong").Code)
+	assert.Equal(t, 200, login(router, "joe@test.com", "right").Code)
+	assert.Equal(t, 403, login(router, "joe@test.com", "wrong").Code)
+	assert.Equal(t, 200, login(router, "joe@test.com", "right").Code)
+}
+
+func TestPerIP(t *testing.T) {
+	router := setupRouter(ratelimit.NewMemoryStore())
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, 200, login(router, "joe@test.com", "right").Code)
+	}
+
+	w := login(router, "joe@test.com", "right")
+
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const StoreMemory = "memory"
+
+// Store keeps the counters and blocks of the limiters. The memory
+// implementation only sees the requests of its own instance, a shared
+// backend such as Redis implements the same operations with INCR, EXPIRE,
+// SET PX, PTTL and DEL so that limits hold across instances.
+type Store interface {
+	// Increment adds one to the counter of key and returns its new value
+	// along with the time left before it expires. A missing or expired
+	// counter starts over at one and expires after ttl.
+	Increment(key string, ttl time.Duration) (int, time.Duration, error)
+	// Block blocks key for duration, replacing any block it has.
+	Block(key string, duration time.Duration) error
+	// Blocked returns the time left before key is unblocked, which is zero
+	// when it isn't blocked.
+	Blocked(key string) (time.Duration, error)
+	// Reset forgets the counter and the block of key.
+	Reset(key string) error
+}
+
+// NewStore returns a store of the RATE_LIMIT_STORE setting.
+func NewStore(driver string) Store {
+	switch driver {
+	case StoreMemory:
+		return NewMemoryStore()
+	default:
+		panic(fmt.Sprintf("unknown rate limit store %q", driver))
+	}
+}
+
+// sweepInterval is how often a memory store drops its expired entries, so
+// that requests from many addresses don't grow it forever.
+const sweepInterval = time.Minute
+
+type entry struct {
+	count     int
+	expiresAt time.Time
+}
+
+// MemoryStore is a Store local to the process. It is safe for concurrent
+// use.
+type MemoryStore struct {
+	mutex     sync.Mutex
+	counters  map[string]entry
+	blocks    map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  map[string]entry{},
+		blocks:    map[string]time.Time{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (store *MemoryStore) Increment(key string, ttl time.Duration) (int, time.Duration, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	store.sweep(now)
+
+	counter, ok := store.counters[key]
+
+	if !ok || !now.Before(counter.expiresAt) {
+		counter = entry{expiresAt: now.Add(ttl)}
+	}
+
+	counter.count++
+	store.counters[key] = counter
+
+	return counter.count, counter.expiresAt.Sub(now), nil
+}
+
+func (store *MemoryStore) Block(key string, duration time.Duration) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.blocks[key] = time.Now().Add(duration)
+
+	return nil
+}
+
+func (store *MemoryStore) Blocked(key string) (time.Duration, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	until, ok := store.blocks[key]
+
+	if !ok {
+		return 0, nil
+	}
+
+	return max(time.Until(until), 0), nil
+}
+
+func (store *MemoryStore) Reset(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.counters, key)
+	delete(store.blocks, key)
+
+	return nil
+}
+
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, counter := range store.counters {
+		if !now.Before(counter.expiresAt) {
+			delete(store.counters, key)
+		}
+	}
+
+	for key, until := range store.blocks {
+		if !now.Before(until) {
+			delete(store.blocks, key)
+		}
+	}
+
+	store.lastSweep = now
+}
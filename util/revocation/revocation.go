@@ -0,0 +1,49 @@
+package revocation
+
+import (
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Revoke adds the jti of an access token to the revocation list until the
+// token would have expired on its own.
+func Revoke(jti string, userId uint, expiresAt time.Time) error {
+	return connection.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userId,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+func IsRevoked(jti string) (bool, error) {
+	var count int64
+
+	err := connection.Db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+
+	return count > 0, err
+}
+
+// PruneExpired deletes the entries whose tokens have expired, since
+// tokens.GetClaims already rejects them.
+func PruneExpired() (int64, error) {
+	result := connection.Db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+	return result.RowsAffected, result.Error
+}
+
+// StartPruning runs PruneExpired in the background every interval.
+func StartPruning(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := PruneExpired(); err != nil {
+				log.Printf("failed to prune the revoked tokens: %v", err)
+			}
+		}
+	}()
+}
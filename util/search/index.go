@@ -0,0 +1,329 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Match scores relative to an exact match of a query term. Synonyms are
+// ranked right after exact matches, then completions of the term being
+// typed, then typos.
+const (
+	exactScore   = 1.0
+	synonymScore = 0.9
+	prefixScore  = 0.8
+	typoScore    = 0.6
+)
+
+type document struct {
+	ownerId uint
+	name    string
+	terms   []string
+}
+
+// Index is an inverted index of food names. It is safe for concurrent use.
+type Index struct {
+	mutex     sync.RWMutex
+	documents map[uint]document
+	postings  map[string]map[uint]struct{}
+	synonyms  map[string][]string
+}
+
+// Result is a food matching a query, along with its relevance.
+type Result struct {
+	ID    uint
+	Score float64
+}
+
+func NewIndex(synonyms [][]string) *Index {
+	index := &Index{
+		documents: map[uint]document{},
+		postings:  map[string]map[uint]struct{}{},
+		synonyms:  map[string][]string{},
+	}
+
+	for _, group := range synonyms {
+		terms := []string{}
+
+		for _, synonym := range group {
+			terms = append(terms, Tokenize(synonym)...)
+		}
+
+		for _, term := range terms {
+			for _, synonym := range terms {
+				if synonym != term && !slices.Contains(index.synonyms[term], synonym) {
+					index.synonyms[term] = append(index.synonyms[term], synonym)
+				}
+			}
+		}
+	}
+
+	return index
+}
+
+// Upsert indexes the name of a food, replacing the previous one if the food
+// was already indexed.
+func (index *Index) Upsert(id uint, ownerId uint, name string) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.remove(id)
+
+	terms := Tokenize(name)
+
+	index.documents[id] = document{ownerId: ownerId, name: strings.ToLower(name), terms: terms}
+
+	for _, term := range terms {
+		if index.postings[term] == nil {
+			index.postings[term] = map[uint]struct{}{}
+		}
+
+		index.postings[term][id] = struct{}{}
+	}
+}
+
+func (index *Index) Remove(id uint) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.remove(id)
+}
+
+func (index *Index) remove(id uint) {
+	document, ok := index.documents[id]
+
+	if !ok {
+		return
+	}
+
+	for _, term := range document.terms {
+		delete(index.postings[term], id)
+
+		if len(index.postings[term]) == 0 {
+			delete(index.postings, term)
+		}
+	}
+
+	delete(index.documents, id)
+}
+
+func (index *Index) Len() int {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	return len(index.documents)
+}
+
+// Search returns the foods of the given owners whose name matches every
+// term of the query, in any order, best matches first. At most limit
+// results are returned.
+func (index *Index) Search(query string, ownerIds []uint, limit int) []Result {
+	queryTerms := Tokenize(query)
+
+	if len(queryTerms) == 0 {
+		return []Result{}
+	}
+
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	// Best score of each query term for each food
+	scores := map[uint][]float64{}
+
+	for i, queryTerm := range queryTerms {
+		// Only the last term can be a word that is still being typed
+		isLast := i == len(queryTerms)-1
+
+		for term, score := range index.expand(queryTerm, isLast) {
+			for id := range index.postings[term] {
+				if !slices.Contains(ownerIds, index.documents[id].ownerId) {
+					continue
+				}
+
+				if scores[id] == nil {
+					scores[id] = make([]float64, len(queryTerms))
+				}
+
+				scores[id][i] = max(scores[id][i], score)
+			}
+		}
+	}
+
+	results := []Result{}
+
+	for id, termScores := range scores {
+		if slices.Contains(termScores, 0) {
+			continue
+		}
+
+		var total float64
+
+		for _, score := range termScores {
+			total += score
+		}
+
+		// Names made of little else than the query come first, so that
+		// "rice" ranks "Rice" above "Rice pudding with raisins"
+		coverage := float64(len(queryTerms)) / float64(max(len(index.documents[id].terms), len(queryTerms)))
+
+		results = append(results, Result{ID: id, Score: total/float64(len(queryTerms)) + 0.1*coverage})
+	}
+
+	slices.SortFunc(results, func(a Result, b Result) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+
+		if nameA, nameB := index.documents[a.ID].name, index.documents[b.ID].name; nameA != nameB {
+			return strings.Compare(nameA, nameB)
+		}
+
+		return int(a.ID) - int(b.ID)
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// expand lists the indexed terms a query term matches, with the score of
+// each match.
+func (index *Index) expand(queryTerm string, isLast bool) map[string]float64 {
+	matches := map[string]float64{}
+
+	add := func(term string, score float64) {
+		if _, ok := index.postings[term]; ok && score > matches[term] {
+			matches[term] = score
+		}
+	}
+
+	add(queryTerm, exactScore)
+
+	for _, synonym := range index.synonyms[queryTerm] {
+		add(synonym, synonymScore)
+	}
+
+	maxEdits := allowedEdits(queryTerm)
+
+	for term := range index.postings {
+		if isLast && len(queryTerm) >= 2 && strings.HasPrefix(term, queryTerm) {
+			add(term, prefixScore)
+		}
+
+		if maxEdits > 0 && abs(len(term)-len(queryTerm)) <= maxEdits && distance(queryTerm, term) <= maxEdits {
+			add(term, typoScore)
+		}
+	}
+
+	return matches
+}
+
+// allowedEdits is the number of typos tolerated in a term. Short terms have
+// to be exact, otherwise "egg" would match "fig".
+func allowedEdits(term string) int {
+	switch length := len([]rune(term)); {
+	case length <= 3:
+		return 0
+	case length <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// distance is the Damerau-Levenshtein distance between two terms, restricted
+// to transpositions of adjacent letters, which are the most common typos.
+func distance(a string, b string) int {
+	runesA, runesB := []rune(a), []rune(b)
+
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	beforePrevious := make([]int, len(runesB)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+
+			if i > 1 && j > 1 && runesA[i-1] == runesB[j-2] && runesA[i-2] == runesB[j-1] {
+				current[j] = min(current[j], beforePrevious[j-2]+1)
+			}
+		}
+
+		beforePrevious, previous, current = previous, current, beforePrevious
+	}
+
+	return previous[len(runesB)]
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
+
+var accents = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a',
+	'ç': 'c',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i',
+	'ñ': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u',
+	'ý': 'y', 'ÿ': 'y',
+}
+
+// Tokenize splits a text into lowercase terms without accents, reducing
+// plurals to their singular so that "eggs" matches "egg".
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := []string{}
+
+	for _, word := range words {
+		term := []rune(word)
+
+		for i, r := range term {
+			if unaccented, ok := accents[r]; ok {
+				term[i] = unaccented
+			}
+		}
+
+		terms = append(terms, singular(string(term)))
+	}
+
+	return terms
+}
+
+func singular(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return strings.TrimSuffix(word, "s")
+	default:
+		return word
+	}
+}
@@ -0,0 +1,108 @@
+package search_test
+
+import (
+	"diet-app-backend/util/search"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newIndex() *search.Index {
+	index := search.NewIndex([][]string{{"eggplant", "aubergine"}})
+
+	index.Upsert(1, 0, "Broccoli, raw")
+	index.Upsert(2, 0, "Chicken breast")
+	index.Upsert(3, 0, "Breast, chicken, roasted")
+	index.Upsert(4, 0, "Aubergine")
+	index.Upsert(5, 0, "Rice")
+	index.Upsert(6, 0, "Rice pudding with raisins")
+	index.Upsert(7, 8, "Grandma's broccoli soup")
+
+	return index
+}
+
+func ids(results []search.Result) []uint {
+	ids := []uint{}
+
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+
+	return ids
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"creme", "brulee"}, search.Tokenize("Crème Brûlée"))
+	assert.Equal(t, []string{"breast", "chicken", "roasted"}, search.Tokenize("Breast, chicken, roasted"))
+	assert.Equal(t, []string{"egg", "berry", "glass"}, search.Tokenize("Eggs berries glass"))
+}
+
+func TestSearchToleratesTypos(t *testing.T) {
+	index := newIndex()
+
+	assert.Equal(t, []uint{1}, ids(index.Search("brocoli", []uint{0}, 10)))
+	assert.Equal(t, []uint{1}, ids(index.Search("borccoli", []uint{0}, 10)))
+}
+
+func TestSearchIgnoresWordOrder(t *testing.T) {
+	index := newIndex()
+
+	assert.Equal(t, []uint{2, 3}, ids(index.Search("chicken breast", []uint{0}, 10)))
+	assert.Equal(t, []uint{2, 3}, ids(index.Search("breast chicken", []uint{0}, 10)))
+}
+
+func TestSearchRequiresEveryTerm(t *testing.T) {
+	index := newIndex()
+
+	assert.Empty(t, index.Search("chicken rice", []uint{0}, 10))
+}
+
+func TestSearchUsesSynonyms(t *testing.T) {
+	index := newIndex()
+
+	assert.Equal(t, []uint{4}, ids(index.Search("eggplant", []uint{0}, 10)))
+}
+
+func TestSearchCompletesTheLastTerm(t *testing.T) {
+	index := newIndex()
+
+	assert.Equal(t, []uint{2, 3}, ids(index.Search("chick", []uint{0}, 10)))
+	// Only the last term is completed
+	assert.Empty(t, index.Search("chick breast", []uint{0}, 10))
+}
+
+func TestSearchRanksExactMatchesFirst(t *testing.T) {
+	index := newIndex()
+
+	results := index.Search("rice", []uint{0}, 10)
+
+	assert.Equal(t, []uint{5, 6}, ids(results))
+	assert.Greater(t, results[0].Score, results[1].Score)
+}
+
+func TestSearchIsRestrictedToOwners(t *testing.T) {
+	index := newIndex()
+
+	assert.Equal(t, []uint{1}, ids(index.Search("broccoli", []uint{0}, 10)))
+	assert.Equal(t, []uint{1, 7}, ids(index.Search("broccoli", []uint{0, 8}, 10)))
+}
+
+func TestSearchLimit(t *testing.T) {
+	index := newIndex()
+
+	assert.Len(t, index.Search("chicken", []uint{0}, 1), 1)
+}
+
+func TestUpsertAndRemove(t *testing.T) {
+	index := newIndex()
+
+	index.Upsert(5, 0, "Brown rice")
+
+	assert.Equal(t, []uint{5}, ids(index.Search("brown", []uint{0}, 10)))
+
+	index.Remove(5)
+
+	assert.Empty(t, index.Search("brown", []uint{0}, 10))
+	assert.Equal(t, []uint{6}, ids(index.Search("rice", []uint{0}, 10)))
+	assert.Equal(t, 6, index.Len())
+}
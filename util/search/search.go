@@ -0,0 +1,117 @@
+package search
+
+import (
+	"diet-app-backend/database/connection"
+	"diet-app-backend/database/models"
+	"log"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Synonyms are the groups of terms that name the same food.
+var Synonyms = [][]string{
+	{"eggplant", "aubergine"},
+	{"zucchini", "courgette"},
+	{"shrimp", "prawn"},
+	{"chickpea", "garbanzo"},
+	{"cilantro", "coriander"},
+	{"arugula", "rocket"},
+	{"yogurt", "yoghurt"},
+	{"soda", "pop"},
+	{"cookie", "biscuit"},
+	{"fries", "chips"},
+}
+
+// index is nil until Load has run, in which case callers fall back to
+// searching the database directly.
+var index atomic.Pointer[Index]
+
+// Load builds the index from the foods table, replacing the current one.
+func Load() error {
+	var foods []models.Food
+
+	if err := connection.Db.Select("id", "user_id", "name").Find(&foods).Error; err != nil {
+		return err
+	}
+
+	newIndex := NewIndex(Synonyms)
+
+	for _, food := range foods {
+		newIndex.Upsert(food.ID, food.UserID, food.Name)
+	}
+
+	index.Store(newIndex)
+
+	return nil
+}
+
+// StartIndexing loads the index, then reloads it in the background every
+// interval. Foods written through this instance are indexed right away, the
+// reloads pick up the ones written by other instances.
+func StartIndexing(interval time.Duration) {
+	if err := Load(); err != nil {
+		log.Printf("failed to load the search index: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := Load(); err != nil {
+				log.Printf("failed to reload the search index: %v", err)
+			}
+		}
+	}()
+}
+
+// Unload drops the index, so that searches fall back to the database until
+// the next Load.
+func Unload() {
+	index.Store(nil)
+}
+
+func Loaded() bool {
+	return index.Load() != nil
+}
+
+// Upsert keeps the index in sync after a food was created or updated.
+func Upsert(food models.Food) {
+	if current := index.Load(); current != nil {
+		current.Upsert(food.ID, food.UserID, food.Name)
+	}
+}
+
+// Remove keeps the index in sync after a food was deleted.
+func Remove(id uint) {
+	if current := index.Load(); current != nil {
+		current.Remove(id)
+	}
+}
+
+// Search returns the ids of all the foods visible to the user matching the
+// query, best matches first. ok is false when the index is not loaded.
+func Search(query string, userId uint) (ids []uint, ok bool) {
+	current := index.Load()
+
+	if current == nil {
+		return nil, false
+	}
+
+	ownerIds := []uint{models.CatalogOwner}
+
+	if userId != models.CatalogOwner {
+		ownerIds = append(ownerIds, userId)
+	}
+
+	ids = []uint{}
+
+	// Every match is returned, as the total of a search and its pages in
+	// the other sort orders must cover all of them
+	for _, result := range current.Search(query, ownerIds, math.MaxInt) {
+		ids = append(ids, result.ID)
+	}
+
+	return ids, true
+}
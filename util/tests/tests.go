@@ -3,18 +3,24 @@ package tests
 import (
 	"diet-app-backend/api/routes"
 	"diet-app-backend/schemas"
+	"diet-app-backend/util/apierrors"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 type LoginResponseBody struct {
-	Token string
+	Token        string
+	RefreshToken string `json:"refresh_token"`
 }
 
 type GenericErrorResponseBody struct {
-	Error string
+	Code    string
+	Message string
+	Details []apierrors.FieldError
 }
 
 func GetToken(email string, password string) string {
@@ -36,3 +42,19 @@ func GetToken(email string, password string) string {
 
 	return responseBody.Token
 }
+
+// ExpectRefreshTokenCreation registers the statements run when a refresh
+// token is persisted, which happens on every successful login.
+func ExpectRefreshTokenCreation(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `refresh_tokens`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+// ExpectTokenNotRevoked registers the revocation list lookup performed by
+// authentication.Authenticate on every authenticated request.
+func ExpectTokenNotRevoked(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `revoked_tokens` WHERE jti = \\?").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+}
@@ -1,7 +1,11 @@
 package tokens
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"diet-app-backend/util/config"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"strings"
 
@@ -21,6 +25,31 @@ func GetClaims(c *gin.Context) (jwt.MapClaims, error) {
 	return validate(splitAuthorizationHeader[1])
 }
 
+// Parse validates a token that doesn't come from the Authorization header,
+// such as the one of an email verification link.
+func Parse(tokenString string) (jwt.MapClaims, error) {
+	return validate(tokenString)
+}
+
+// GenerateOpaqueToken returns a random URL-safe string suitable for tokens
+// that are persisted server side, such as refresh tokens.
+func GenerateOpaqueToken() (string, error) {
+	bytes := make([]byte, 32)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// HashOpaqueToken returns the digest under which an opaque token is stored,
+// so that a database leak does not expose usable tokens.
+func HashOpaqueToken(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
 func validate(tokenString string) (jwt.MapClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
 		// Don't forget to validate the alg is what you expect:
@@ -32,7 +61,7 @@ func validate(tokenString string) (jwt.MapClaims, error) {
 			"-----BEGIN PUBLIC KEY-----\n%s\n-----END PUBLIC KEY-----",
 			config.AppConfig.JwtPublicKey,
 		)))
-	})
+	}, jwt.WithExpirationRequired(), jwt.WithIssuedAt())
 
 	if err != nil {
 		return make(jwt.MapClaims), err
@@ -0,0 +1,81 @@
+package totp_test
+
+import (
+	"diet-app-backend/util/totp"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 secret of the test vectors of RFC 6238,
+// "12345678901234567890" in base 32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// The RFC lists 8 digit codes, these are their last 6 digits
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for seconds, expected := range vectors {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(seconds, 0)))
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code, seconds)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code, _ := totp.Code(rfcSecret, totp.Step(now))
+
+	step, ok := totp.Match(rfcSecret, code, now)
+
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	// The code is still accepted one period later
+	_, ok = totp.Match(rfcSecret, code, now.Add(totp.Period))
+
+	assert.True(t, ok)
+
+	_, ok = totp.Match(rfcSecret, code, now.Add(2*totp.Period))
+
+	assert.False(t, ok)
+
+	_, ok = totp.Match(rfcSecret, "000000", now)
+
+	assert.False(t, ok)
+
+	_, ok = totp.Match(rfcSecret, "0818", now)
+
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+
+	assert.Nil(t, err)
+	assert.Len(t, secret, 32)
+
+	_, err = totp.Code(secret, 1)
+
+	assert.Nil(t, err)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, _ := url.Parse(totp.ProvisioningURI("Diet App", "joe@test.com", rfcSecret))
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Diet App:joe@test.com", uri.Path)
+	assert.Equal(t, rfcSecret, uri.Query().Get("secret"))
+	assert.Equal(t, "Diet App", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+}
@@ -0,0 +1,66 @@
+package trends
+
+import (
+	"diet-app-backend/util/dates"
+	"time"
+)
+
+// Sample is a value observed at an instant.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// Day is the mean of the samples of a day, along with the moving average of
+// the days that led to it.
+type Day struct {
+	Date    time.Time
+	Mean    float64
+	Average float64
+}
+
+// MovingAverage averages the samples of each day in loc, then smooths the
+// daily means with a moving average over the window days ending on each day.
+// Days without samples are left out rather than counted as zero, so that
+// skipping the scale for a few days doesn't drag the average down. Samples
+// must be sorted by time.
+func MovingAverage(samples []Sample, window int, loc *time.Location) []Day {
+	days := []Day{}
+	counts := []int{}
+
+	for _, sample := range samples {
+		date := dates.StartOfDay(sample.Time, loc)
+		last := len(days) - 1
+
+		if last >= 0 && days[last].Date.Equal(date) {
+			days[last].Mean += sample.Value
+			counts[last]++
+			continue
+		}
+
+		days = append(days, Day{Date: date, Mean: sample.Value})
+		counts = append(counts, 1)
+	}
+
+	for i := range days {
+		days[i].Mean /= float64(counts[i])
+	}
+
+	first := 0
+	sum := 0.0
+
+	for i := range days {
+		sum += days[i].Mean
+
+		windowStart := days[i].Date.AddDate(0, 0, 1-window)
+
+		for days[first].Date.Before(windowStart) {
+			sum -= days[first].Mean
+			first++
+		}
+
+		days[i].Average = sum / float64(i-first+1)
+	}
+
+	return days
+}
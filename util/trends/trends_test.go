@@ -0,0 +1,57 @@
+package trends_test
+
+import (
+	"diet-app-backend/util/trends"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMovingAverage(t *testing.T) {
+	day := time.Date(2024, time.May, 1, 8, 0, 0, 0, time.UTC)
+
+	samples := []trends.Sample{
+		{Time: day, Value: 80},
+		// Two weigh-ins on the same day are averaged
+		{Time: day.AddDate(0, 0, 1), Value: 81},
+		{Time: day.AddDate(0, 0, 1).Add(12 * time.Hour), Value: 82},
+		// Nothing was logged on the 3rd
+		{Time: day.AddDate(0, 0, 3), Value: 79},
+		{Time: day.AddDate(0, 0, 4), Value: 78},
+	}
+
+	days := trends.MovingAverage(samples, 3, time.UTC)
+
+	assert.Len(t, days, 4)
+
+	assert.Equal(t, time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC), days[0].Date)
+	assert.InDelta(t, 80.0, days[0].Mean, 0.001)
+	assert.InDelta(t, 80.0, days[0].Average, 0.001)
+
+	assert.InDelta(t, 81.5, days[1].Mean, 0.001)
+	assert.InDelta(t, 80.75, days[1].Average, 0.001)
+
+	// The window of the 4th covers the 2nd to the 4th
+	assert.Equal(t, time.Date(2024, time.May, 4, 0, 0, 0, 0, time.UTC), days[2].Date)
+	assert.InDelta(t, 80.25, days[2].Average, 0.001)
+
+	assert.InDelta(t, 78.5, days[3].Average, 0.001)
+}
+
+func TestMovingAverageInTimezone(t *testing.T) {
+	location, _ := time.LoadLocation("America/New_York")
+
+	samples := []trends.Sample{
+		{Time: time.Date(2024, time.May, 2, 1, 0, 0, 0, time.UTC), Value: 80},
+		{Time: time.Date(2024, time.May, 2, 13, 0, 0, 0, time.UTC), Value: 82},
+	}
+
+	days := trends.MovingAverage(samples, 7, location)
+
+	// 01:00 UTC is still the 1st in New York
+	assert.Len(t, days, 2)
+	assert.Equal(t, 1, days[0].Date.Day())
+	assert.Equal(t, 2, days[1].Date.Day())
+	assert.InDelta(t, 81.0, days[1].Average, 0.001)
+}